@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fnatte/pizza-tribes/internal"
 	"github.com/form3tech-oss/jwt-go"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
@@ -30,6 +31,7 @@ Redis details
 
 - Users are stored as hashes in user:{userid}
 - User ids can be looked up using username:{username}
+- Users are searchable by username prefix in index:usernames
 
 */
 
@@ -86,6 +88,7 @@ func (a *AuthService) Register(ctx context.Context, username, password string) e
 		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
 			pipe.Set(ctx, usernameKey, id, 0)
 			pipe.HSet(ctx, userKey, "id", id, "username", username, "hashed_password", hash)
+			internal.IndexUsername(ctx, pipe, id, username)
 			return nil
 		})
 		return err
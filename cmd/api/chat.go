@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+type ChatController struct {
+	chat *internal.ChatService
+	auth *AuthService
+}
+
+func (c *ChatController) Handler() http.Handler {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		channel := models.ChatMessage_GLOBAL
+		paramChannel := r.URL.Query().Get("channel")
+		if paramChannel != "" {
+			v, ok := models.ChatMessage_Channel_value[strings.ToUpper(paramChannel)]
+			if !ok {
+				w.WriteHeader(400)
+				return
+			}
+			channel = models.ChatMessage_Channel(v)
+		}
+
+		username := r.URL.Query().Get("username")
+		if channel == models.ChatMessage_PRIVATE && username == "" {
+			w.WriteHeader(400)
+			return
+		}
+
+		skip := 0
+		paramSkip := r.URL.Query().Get("skip")
+		if paramSkip != "" {
+			if skip, err = strconv.Atoi(paramSkip); err != nil || skip < 0 {
+				w.WriteHeader(400)
+				log.Error().Err(err).Msg("Could not parse skip")
+				return
+			}
+		}
+
+		history, err := c.chat.GetHistory(r.Context(), userId, channel, username, skip)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get chat history")
+			return
+		}
+
+		writeProto(w, history)
+	})
+
+	r.HandleFunc("/ignored", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		usernames, err := c.chat.GetIgnored(r.Context(), userId)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get ignored users")
+			return
+		}
+
+		b, err := json.Marshal(struct {
+			Usernames []string `json:"usernames"`
+		}{
+			Usernames: usernames,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to marshal ignored users")
+			w.WriteHeader(500)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b)
+	})
+
+	return r
+}
@@ -44,16 +44,22 @@ func main() {
 	auth := NewAuthService(rc)
 	world := internal.NewWorldService(rc)
 	leaderboard := internal.NewLeaderboardService(rc)
+	market := internal.NewMarketService(rc)
+	tribes := internal.NewTribeService(rc)
+	chat := internal.NewChatService(rc, world)
 	wsHub := ws.NewHub()
 	handler := wsHandler{rc: rc, world: world}
 	wsEndpoint := ws.NewEndpoint(auth.Authorize, wsHub, &handler, origin)
 	poller := poller{rdb: rc, hub: wsHub}
 	ts := &TimeseriesService{r: rc, auth: auth}
-	worldController := &WorldController{auth: auth, world: world}
+	worldController := &WorldController{auth: auth, world: world, r: rc}
 	userController := &UserController{auth: auth, r: rc}
 	leaderboardController := &LeaderboardController{
 		auth:        auth,
 		leaderboard: leaderboard}
+	marketController := &MarketController{auth: auth, market: market}
+	tribeController := &TribeController{auth: auth, tribes: tribes}
+	chatController := &ChatController{auth: auth, chat: chat}
 
 	r := mux.NewRouter()
 	r.Handle("/ws", wsEndpoint)
@@ -63,6 +69,9 @@ func main() {
 	registerSubrouter(r, "/world", worldController.Handler())
 	registerSubrouter(r, "/user", userController.Handler())
 	registerSubrouter(r, "/leaderboard", leaderboardController.Handler())
+	registerSubrouter(r, "/market", marketController.Handler())
+	registerSubrouter(r, "/tribe", tribeController.Handler())
+	registerSubrouter(r, "/chat", chatController.Handler())
 
 	// Start web socket loop
 	go wsHub.Run()
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+type MarketController struct {
+	market *internal.MarketService
+	auth   *AuthService
+}
+
+func (c *MarketController) Handler() http.Handler {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+
+		book, err := c.market.GetOrderBook(r.Context())
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get market order book")
+			return
+		}
+
+		b, err := protojson.Marshal(book)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to marshal market order book")
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b)
+	})
+
+	r.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		orders, err := c.market.GetUserOrders(r.Context(), userId)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get market orders")
+			return
+		}
+
+		book := &models.MarketOrderBook{}
+		for _, o := range orders {
+			if o.Side == models.MarketOrder_BUY {
+				book.Buys = append(book.Buys, o)
+			} else {
+				book.Sells = append(book.Sells, o)
+			}
+		}
+
+		b, err := protojson.Marshal(book)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to marshal market orders")
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b)
+	})
+
+	return r
+}
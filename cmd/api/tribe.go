@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+)
+
+type TribeController struct {
+	tribes *internal.TribeService
+	auth   *AuthService
+}
+
+func writeProto(w http.ResponseWriter, m proto.Message) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		w.WriteHeader(500)
+		log.Error().Err(err).Msg("Failed to marshal response")
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+func (c *TribeController) Handler() http.Handler {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		tribe, err := c.tribes.GetUserTribe(r.Context(), userId)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get tribe")
+			return
+		}
+		if tribe == nil {
+			w.WriteHeader(404)
+			return
+		}
+
+		writeProto(w, tribe)
+	})
+
+	r.HandleFunc("/invitations", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		invitations, err := c.tribes.GetInvitations(r.Context(), userId)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get tribe invitations")
+			return
+		}
+
+		writeProto(w, invitations)
+	})
+
+	r.HandleFunc("/{tribeId}", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+
+		tribe, err := c.tribes.Get(r.Context(), mux.Vars(r)["tribeId"])
+		if errors.Is(err, redis.Nil) {
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get tribe")
+			return
+		}
+
+		writeProto(w, tribe)
+	})
+
+	return r
+}
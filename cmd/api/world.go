@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/combat"
 	"github.com/fnatte/pizza-tribes/internal/models"
 	"github.com/fnatte/pizza-tribes/internal/protojson"
 	"github.com/gorilla/mux"
@@ -81,5 +87,246 @@ func (c *WorldController) Handler() http.Handler {
 		w.Write(b)
 	})
 
+	r.HandleFunc("/viewport", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+
+		var bbox [4]int
+		for i, param := range []string{"x1", "y1", "x2", "y2"} {
+			if bbox[i], err = strconv.Atoi(r.URL.Query().Get(param)); err != nil {
+				w.WriteHeader(400)
+				log.Error().Err(err).Msg("Param x1, y1, x2 and y2 are required")
+				return
+			}
+		}
+
+		viewport, err := c.world.GetViewport(r.Context(), bbox[0], bbox[1], bbox[2], bbox[3])
+		if err == internal.ErrViewportTooLarge {
+			w.WriteHeader(400)
+			log.Warn().Err(err).Ints("bbox", bbox[:]).Msg("Viewport is too large")
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to get viewport")
+			return
+		}
+
+		b, err := protojson.Marshal(viewport)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to marshal viewport")
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b)
+	})
+
+	r.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		var result *models.TownSearchResult
+		if username := r.URL.Query().Get("username"); username != "" {
+			result, err = c.world.SearchTownsByUsername(r.Context(), userId, username)
+		} else {
+			radius := internal.TownSearchMaxRadius
+			if paramRadius := r.URL.Query().Get("radius"); paramRadius != "" {
+				if radius, err = strconv.Atoi(paramRadius); err != nil || radius <= 0 || radius > internal.TownSearchMaxRadius {
+					w.WriteHeader(400)
+					log.Error().Err(err).Str("radius", paramRadius).Msg("Invalid radius")
+					return
+				}
+			}
+			result, err = c.world.SearchNearbyTowns(r.Context(), userId, radius)
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to search towns")
+			return
+		}
+
+		b, err := protojson.Marshal(result)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to marshal town search result")
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b)
+	})
+
+	r.HandleFunc("/heist", func(w http.ResponseWriter, r *http.Request) {
+		err := c.auth.Authorize(r)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authorize")
+			w.WriteHeader(403)
+			return
+		}
+		userId, ok := r.Context().Value("userId").(string)
+		if !ok {
+			log.Warn().Msg("Failed to get account id")
+			w.WriteHeader(500)
+			return
+		}
+
+		var x, y, thieves int
+		if x, err = strconv.Atoi(r.URL.Query().Get("x")); err != nil {
+			w.WriteHeader(400)
+			log.Error().Err(err).Msg("Param x, y and thieves are required")
+			return
+		}
+		if y, err = strconv.Atoi(r.URL.Query().Get("y")); err != nil {
+			w.WriteHeader(400)
+			log.Error().Err(err).Msg("Param x, y and thieves are required")
+			return
+		}
+		if thieves, err = strconv.Atoi(r.URL.Query().Get("thieves")); err != nil || thieves <= 0 {
+			w.WriteHeader(400)
+			log.Error().Err(err).Msg("Param x, y and thieves are required")
+			return
+		}
+		resource := models.Resource_COINS
+		if paramResource := r.URL.Query().Get("resource"); paramResource != "" {
+			v, ok := models.Resource_value[strings.ToUpper(paramResource)]
+			if !ok {
+				w.WriteHeader(400)
+				log.Error().Str("resource", paramResource).Msg("Invalid resource")
+				return
+			}
+			resource = models.Resource(v)
+		}
+
+		preview, err := c.previewHeist(r.Context(), userId, int32(x), int32(y), thieves, resource)
+		if err == errNotEnoughThieves {
+			w.WriteHeader(400)
+			log.Warn().Int("thieves", thieves).Msg("Not enough thieves to preview heist")
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to preview heist")
+			return
+		}
+		if preview == nil {
+			w.WriteHeader(404)
+			return
+		}
+
+		b, err := protojson.Marshal(preview)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error().Err(err).Msg("Failed to marshal heist preview")
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b)
+	})
+
 	return r
 }
+
+var errNotEnoughThieves = errors.New("not enough thieves")
+
+// Previews a heist on the town at x, y using only what the user knows about
+// it from scouting. Returns nil if there is no town to steal from there, or
+// if it can not be reached.
+func (c *WorldController) previewHeist(ctx context.Context, userId string, x, y int32, thieves int, resource models.Resource) (*models.HeistPreview, error) {
+	size, err := c.world.GetSize(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get world size: %w", err)
+	}
+	if !size.Contains(int(x), int(y)) {
+		return nil, nil
+	}
+
+	entry, err := c.world.GetEntryXY(ctx, int(x), int(y))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get world entry: %w", err)
+	}
+	town := entry.GetTown()
+	if town == nil || town.UserId == userId {
+		return nil, nil
+	}
+
+	gs := &models.GameState{}
+	s, err := internal.RedisJsonGet(c.r, ctx, fmt.Sprintf("user:%s:gamestate", userId), ".").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game state: %w", err)
+	}
+	if err = protojson.Unmarshal([]byte(s), gs); err != nil {
+		return nil, fmt.Errorf("failed to get game state: %w", err)
+	}
+
+	// Only heists that the user can send are previewed. This also keeps
+	// the number of thieves in range before it is converted to int32.
+	if gs.Population == nil || thieves > int(gs.Population.Thieves) {
+		return nil, errNotEnoughThieves
+	}
+
+	gsTarget := &models.GameState{}
+	s, err = internal.RedisJsonGet(c.r, ctx, fmt.Sprintf("user:%s:gamestate", town.UserId), ".").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target game state: %w", err)
+	}
+	if err = protojson.Unmarshal([]byte(s), gsTarget); err != nil {
+		return nil, fmt.Errorf("failed to get target game state: %w", err)
+	}
+
+	reports, err := internal.GetReports(ctx, c.r, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+
+	intel := internal.GetHeistIntel(internal.FindLatestScoutReport(reports, x, y), resource, int32(thieves))
+
+	// Thieves sent to a town they may not steal from return empty-handed
+	var preview *models.HeistPreview
+	err = internal.CheckCanSteal(ctx, c.r, userId, town.UserId, gsTarget)
+	switch err {
+	case nil:
+		preview = internal.PreviewHeist(intel, resource, int32(thieves), combat.GetLevel(gs.ThiefExperience))
+	case internal.ErrTargetProtected, internal.ErrStealCooldown:
+		preview = &models.HeistPreview{
+			Thieves:            int32(thieves),
+			Resource:           resource,
+			NotStealableReason: err.Error(),
+		}
+	default:
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	preview.X = x
+	preview.Y = y
+	preview.ArrivalAt, err = c.world.CalculateArrivalTime(ctx, gs.TownX, gs.TownY, x, y, internal.ThiefSpeed)
+	if err == internal.ErrNoPath {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate arrival time: %w", err)
+	}
+	preview.TravelTime = (preview.ArrivalAt - now) / int64(time.Second)
+
+	return preview, nil
+}
@@ -3,6 +3,8 @@ package ws
 type Message struct {
 	Recipient string
 	Body      []byte
+	Broadcast bool
+	Exclude   map[string]bool
 }
 
 // The Hub maintain all web socket clients. When started (using Run()),
@@ -40,6 +42,15 @@ func (h *Hub) SendTo(recipient string, body []byte) {
 	h.messages <- &Message{Recipient: recipient, Body: body}
 }
 
+// Sends bytes to all connected users, except for the excluded user ids.
+func (h *Hub) Broadcast(body []byte, excludeIds []string) {
+	exclude := map[string]bool{}
+	for _, id := range excludeIds {
+		exclude[id] = true
+	}
+	h.messages <- &Message{Body: body, Broadcast: true, Exclude: exclude}
+}
+
 // Starts the Hub (and blocks). It will pull messages and send them to the
 // corresponding client.
 func (h *Hub) Run() {
@@ -55,7 +66,10 @@ func (h *Hub) Run() {
 		case msg := <-h.messages:
 			// TODO: should probably optimize this
 			for client := range h.clients {
-				if client.userId == msg.Recipient {
+				if msg.Broadcast && msg.Exclude[client.userId] {
+					continue
+				}
+				if msg.Broadcast || client.userId == msg.Recipient {
 					select {
 					case client.send <- []byte(msg.Body):
 					default:
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/fnatte/pizza-tribes/cmd/api/ws"
 	"github.com/fnatte/pizza-tribes/internal"
@@ -53,6 +54,7 @@ func (h *wsHandler) HandleInit(ctx context.Context, c *ws.Client) error {
 		if err != redis.Nil {
 			return err
 		}
+		gs.ProtectedUntil = time.Now().Add(internal.BeginnerProtectionDuration).UnixNano()
 		b, err := protojson.MarshalOptions{
 			EmitUnpopulated: true,
 		}.Marshal(&gs)
@@ -153,5 +155,36 @@ func (h *wsHandler) HandleInit(ctx context.Context, c *ws.Client) error {
 		log.Debug().Msg("Sent init reports")
 	})()
 
+	// Send mailbox
+	go (func() {
+		letters, err := internal.GetLetters(ctx, h.rc, c.UserId())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send inital mailbox")
+			return
+		}
+		unread, err := internal.CountUnreadLetters(ctx, h.rc, c.UserId())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send inital mailbox")
+			return
+		}
+
+		msg := &models.ServerMessage{
+			Id: xid.New().String(),
+			Payload: &models.ServerMessage_Mailbox_{
+				Mailbox: &models.ServerMessage_Mailbox{
+					Letters:     letters,
+					UnreadCount: int32(unread),
+				},
+			},
+		}
+		b, err := protojson.Marshal(msg)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send inital mailbox")
+			return
+		}
+		c.Send(b)
+		log.Debug().Msg("Sent init mailbox")
+	})()
+
 	return nil
 }
@@ -34,7 +34,11 @@ func (p *poller) run(ctx context.Context) {
 		msg := &internal.OutgoingMessage{}
 		msg.UnmarshalBinary([]byte(res[1]))
 
-		p.hub.SendTo(msg.ReceiverId, []byte(msg.Body))
+		if msg.Broadcast {
+			p.hub.Broadcast([]byte(msg.Body), msg.ExcludeIds)
+		} else {
+			p.hub.SendTo(msg.ReceiverId, []byte(msg.Body))
+		}
 	}
 }
 
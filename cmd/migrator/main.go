@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/fnatte/pizza-tribes/internal"
 	"github.com/go-redis/redis/v8"
@@ -18,11 +20,43 @@ func envOrDefault(key string, defaultVal string) string{
 }
 
 func ensureWorld(ctx context.Context, r internal.RedisClient) error {
+	// A fixed seed makes the world reproducible, e.g. for testing
+	if s, ok := os.LookupEnv("WORLD_SEED"); ok {
+		seed, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid world seed: %w", err)
+		}
+		if err = internal.EnsureWorldSeed(ctx, r, seed); err != nil {
+			return err
+		}
+	}
+
 	world := internal.NewWorldService(r)
 	if err := world.Initilize(ctx); err != nil {
 		return err
 	}
 
+	// Grow the world before storing the terrain and placing NPC towns, so
+	// that new zones get them too
+	if _, err := world.GrowWhenLow(ctx); err != nil {
+		return err
+	}
+
+	if err := world.StoreTerrain(ctx); err != nil {
+		return err
+	}
+
+	// NPC towns are placed after the zones have been opened, since only
+	// empty zones are opened
+	if err := world.PlaceNpcTowns(ctx); err != nil {
+		return err
+	}
+
+	// Towns acquired before the search indexes existed are not in them
+	if err := world.RebuildSearchIndexes(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
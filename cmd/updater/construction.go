@@ -8,7 +8,10 @@ import (
 )
 
 func completedConstructions(ctx updateContext) (error) {
-	completedConstructions := getCompletedConstructions(ctx.gs)
+	// Constructions run in parallel slots, so any item in the queue can
+	// be completed regardless of its position.
+	completedConstructions, ongoingConstructions := internal.SplitCompletedConstructions(
+		ctx.gs.ConstructionQueue, time.Now().UnixNano())
 
 	// Exit early if there are no completed constructions
 	if len(completedConstructions) == 0 {
@@ -16,7 +19,7 @@ func completedConstructions(ctx updateContext) (error) {
 	}
 
 	// Update patch
-	ctx.patch.gsPatch.ConstructionQueue = ctx.gs.ConstructionQueue[len(completedConstructions):]
+	ctx.patch.gsPatch.ConstructionQueue = ongoingConstructions
 	ctx.patch.gsPatch.ConstructionQueuePatched = true
 	ctx.patch.gsPatch.Lots = map[string]*models.GameStatePatch_LotPatch{}
 
@@ -51,49 +54,7 @@ func completedConstructions(ctx updateContext) (error) {
 					ctx.IncrUneducated(levelInfo.Residence.Beds)
 				}
 			} else {
-				rest := levelInfo.Residence.Beds
-
-				if rest > ctx.gs.Population.Uneducated {
-					ctx.IncrUneducated(-ctx.gs.Population.Uneducated)
-					rest = rest - ctx.gs.Population.Uneducated
-				} else {
-					ctx.IncrUneducated(-rest)
-					rest = 0
-				}
-
-				popKey := 0
-				loopCount := 0
-				for rest > 0 && loopCount < 1000 {
-					switch popKey {
-					case 0:
-						if ctx.gs.Population.Chefs > 0 {
-							ctx.IncrChefs(-1)
-							rest = rest - 1
-						}
-					case 1:
-						if ctx.gs.Population.Salesmice > 0 {
-							ctx.IncrSalesmice(-1)
-							rest = rest - 1
-						}
-					case 2:
-						if ctx.gs.Population.Guards > 0 {
-							ctx.IncrGuards(-1)
-							rest = rest - 1
-						}
-					case 3:
-						if ctx.gs.Population.Thieves > 0 {
-							ctx.IncrThieves(-1)
-							rest = rest - 1
-						}
-					case 4:
-						if ctx.gs.Population.Publicists > 0 {
-							ctx.IncrPublicists(-1)
-							rest = rest - 1
-						}
-					}
-					popKey = (popKey + 1) % 5
-					loopCount++
-				}
+				ctx.EvictPopulation(levelInfo.Residence.Beds)
 			}
 		}
 	}
@@ -105,17 +66,3 @@ func completedConstructions(ctx updateContext) (error) {
 
 	return nil
 }
-
-func getCompletedConstructions(gs *models.GameState) (res []*models.Construction) {
-	now := time.Now().UnixNano()
-
-	for _, t := range gs.ConstructionQueue {
-		if t.CompleteAt > now {
-			break
-		}
-
-		res = append(res, t)
-	}
-
-	return res
-}
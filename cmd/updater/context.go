@@ -115,3 +115,96 @@ func (u *updateContext) IncrPublicists(amount int32) {
 	u.gs.Population.Publicists = u.patch.gsPatch.Population.Publicists.Value
 }
 
+func (u *updateContext) IncrScouts(amount int32) {
+	if u.patch.gsPatch.Population.Scouts == nil {
+		u.patch.gsPatch.Population.Scouts = &wrapperspb.Int32Value{
+			Value: u.gs.Population.Scouts,
+		}
+	}
+
+	u.patch.gsPatch.Population.Scouts.Value = u.patch.gsPatch.Population.Scouts.Value + amount
+	u.gs.Population.Scouts = u.patch.gsPatch.Population.Scouts.Value
+}
+
+func (u *updateContext) IncrSaboteurs(amount int32) {
+	if u.patch.gsPatch.Population.Saboteurs == nil {
+		u.patch.gsPatch.Population.Saboteurs = &wrapperspb.Int32Value{
+			Value: u.gs.Population.Saboteurs,
+		}
+	}
+
+	u.patch.gsPatch.Population.Saboteurs.Value = u.patch.gsPatch.Population.Saboteurs.Value + amount
+	u.gs.Population.Saboteurs = u.patch.gsPatch.Population.Saboteurs.Value
+}
+
+// Removes the amount of mice from the town, e.g. when beds are lost. The
+// uneducated leave first and then the educated take turns leaving.
+func (u *updateContext) EvictPopulation(amount int32) {
+	rest := amount
+
+	if rest > u.gs.Population.Uneducated {
+		u.IncrUneducated(-u.gs.Population.Uneducated)
+		rest = rest - u.gs.Population.Uneducated
+	} else {
+		u.IncrUneducated(-rest)
+		rest = 0
+	}
+
+	popKey := 0
+	loopCount := 0
+	for rest > 0 && loopCount < 1000 {
+		switch popKey {
+		case 0:
+			if u.gs.Population.Chefs > 0 {
+				u.IncrChefs(-1)
+				rest = rest - 1
+			}
+		case 1:
+			if u.gs.Population.Salesmice > 0 {
+				u.IncrSalesmice(-1)
+				rest = rest - 1
+			}
+		case 2:
+			if u.gs.Population.Guards > 0 {
+				u.IncrGuards(-1)
+				rest = rest - 1
+			}
+		case 3:
+			if u.gs.Population.Thieves > 0 {
+				u.IncrThieves(-1)
+				rest = rest - 1
+			}
+		case 4:
+			if u.gs.Population.Publicists > 0 {
+				u.IncrPublicists(-1)
+				rest = rest - 1
+			}
+		case 5:
+			if u.gs.Population.Scouts > 0 {
+				u.IncrScouts(-1)
+				rest = rest - 1
+			}
+		case 6:
+			if u.gs.Population.Saboteurs > 0 {
+				u.IncrSaboteurs(-1)
+				rest = rest - 1
+			}
+		}
+		popKey = (popKey + 1) % 7
+		loopCount++
+	}
+}
+
+// Returns a context that changes the game state of another user, e.g. the
+// target of a travel. The changes end up in the patch of that user.
+func (u *updateContext) forUser(userId string, gs *models.GameState) *updateContext {
+	u.initPatch(userId)
+	return &updateContext{
+		Context: u.Context,
+		userId:  userId,
+		gs:      gs,
+		patch:   u.patches[userId],
+		patches: u.patches,
+		reports: u.reports,
+	}
+}
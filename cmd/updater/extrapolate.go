@@ -49,14 +49,20 @@ func calculateExtrapolateChanges(gs *models.GameState) extrapolateChanges {
 	pizzasSold := internal.MinInt32(demand,
 		internal.MinInt32(maxSellsByMice, pizzasAvailable))
 
+	// Guards stationed in other towns are paid for by the owner, but the
+	// upkeep never takes more coins than there are
+	upkeep := internal.MinInt32(internal.CalculateUpkeep(gs, gs.Timestamp, now.Unix()),
+		gs.Resources.Coins+pizzasSold)
+
 	log.Debug().
 		Int32("pizzasProduced", pizzasProduced).
 		Int32("maxSellsByMice", maxSellsByMice).
 		Int32("pizzasSold", pizzasSold).
+		Int32("upkeep", upkeep).
 		Msg("Game state update")
 
 	return extrapolateChanges{
-		coins:     pizzasSold,
+		coins:     pizzasSold - upkeep,
 		pizzas:    pizzasProduced - pizzasSold,
 		timestamp: now.Unix(),
 	}
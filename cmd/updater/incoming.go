@@ -0,0 +1,23 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+)
+
+// Removes the incoming travels that have arrived, since they are no longer
+// on their way
+func expireIncomingTravels(ctx updateContext) error {
+	ids := internal.GetExpiredIncomingTravels(ctx.gs, time.Now().UnixNano())
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx.patch.gsPatch.ExpiredIncomingTravels = ids
+	for _, id := range ids {
+		delete(ctx.gs.IncomingTravels, id)
+	}
+
+	return nil
+}
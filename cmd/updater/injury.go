@@ -0,0 +1,25 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+)
+
+// Puts guards that have recovered from their injuries back on duty
+func completeRecoveries(ctx updateContext) error {
+	ids := internal.GetRecoveredInjuries(ctx.gs, time.Now().UnixNano())
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx.patch.gsPatch.RecoveredInjuries = ids
+	for _, id := range ids {
+		ctx.IncrGuards(ctx.gs.Injuries[id].Guards)
+		delete(ctx.gs.Injuries, id)
+	}
+
+	ctx.patch.sendStats = true
+
+	return nil
+}
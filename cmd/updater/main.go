@@ -31,6 +31,10 @@ func getPopulationKey(edu models.Education) (string, error) {
 		return "thieves", nil
 	case models.Education_PUBLICIST:
 		return "publicists", nil
+	case models.Education_SCOUT:
+		return "scouts", nil
+	case models.Education_SABOTEUR:
+		return "saboteurs", nil
 	default:
 		return "", fmt.Errorf("Invalid education: %s", edu)
 	}
@@ -40,6 +44,7 @@ type updater struct {
 	r           internal.RedisClient
 	world       *internal.WorldService
 	leaderboard *internal.LeaderboardService
+	market      *internal.MarketService
 }
 
 func (ctx *updateContext) initPatch(userId string) {
@@ -104,18 +109,36 @@ func (u *updater) update(ctx context.Context, userId string) {
 		if err = extrapolate(uctx); err != nil {
 			return err
 		}
+		if err = endBeginnerProtection(uctx); err != nil {
+			return err
+		}
 		if err = completedConstructions(uctx); err != nil {
 			return err
 		}
+		if err = completeRepairs(uctx); err != nil {
+			return err
+		}
 		if err = completeTrainings(uctx); err != nil {
 			return err
 		}
 		if err = completeTravels(uctx, u.r, u.world); err != nil {
 			return err
 		}
+		if err = expireIncomingTravels(uctx); err != nil {
+			return err
+		}
+		if err = recallUnpaidReinforcements(uctx, u.world); err != nil {
+			return err
+		}
+		if err = completeRecoveries(uctx); err != nil {
+			return err
+		}
 		if err = completeResearchs(uctx); err != nil {
 			return err
 		}
+		if err = updateNpc(uctx, u.r, u.world); err != nil {
+			return err
+		}
 
 		// Prepare redis pipes from patches
 		pipeFns := []pipeFn{}
@@ -191,6 +214,26 @@ func (u *updater) patchToPipe(ctx updateContext, userId string, p *patch) (pipeF
 	var err error
 	gsKey := fmt.Sprintf("user:%s:gamestate", userId)
 
+	// Map entries can not be written until their maps exist
+	if hasReinforcementEntries(p.gsPatch.StationedGuards) {
+		err = internal.RedisJsonEnsureObject(u.r, ctx, gsKey, ".stationedGuards")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hasReinforcementEntries(p.gsPatch.Reinforcements) {
+		err = internal.RedisJsonEnsureObject(u.r, ctx, gsKey, ".reinforcements")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.gsPatch.Injuries) > 0 {
+		err = internal.RedisJsonEnsureObject(u.r, ctx, gsKey, ".injuries")
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return func(pipe redis.Pipeliner) error {
 		// Write timestamp
 		if p.gsPatch.Timestamp != nil {
@@ -212,6 +255,16 @@ func (u *updater) patchToPipe(ctx updateContext, userId string, p *patch) (pipeF
 			}
 		}
 
+		// Write beginner protection
+		if p.gsPatch.ProtectedUntil != nil {
+			err = internal.RedisJsonSet(
+				pipe, ctx, gsKey, ".protectedUntil",
+				p.gsPatch.ProtectedUntil.Value).Err()
+			if err != nil {
+				return fmt.Errorf("failed to write beginner protection: %w", err)
+			}
+		}
+
 		// Write pizzas
 		if p.gsPatch.Resources.Pizzas != nil {
 			err = internal.RedisJsonSet(
@@ -296,6 +349,22 @@ func (u *updater) patchToPipe(ctx updateContext, userId string, p *patch) (pipeF
 				return fmt.Errorf("failed to increase publicists: %w", err)
 			}
 		}
+		if pop.Scouts != nil {
+			_, err = internal.RedisJsonSet(
+				pipe, ctx, gsKey, ".population.scouts",
+				int64(pop.Scouts.Value)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to increase scouts: %w", err)
+			}
+		}
+		if pop.Saboteurs != nil {
+			_, err = internal.RedisJsonSet(
+				pipe, ctx, gsKey, ".population.saboteurs",
+				int64(pop.Saboteurs.Value)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to increase saboteurs: %w", err)
+			}
+		}
 
 		// Write training queue
 		if p.gsPatch.TrainingQueuePatched {
@@ -369,6 +438,58 @@ func (u *updater) patchToPipe(ctx updateContext, userId string, p *patch) (pipeF
 			}
 		}
 
+		// Write reinforcements
+		for hostId, r := range p.gsPatch.StationedGuards {
+			err = internal.SaveReinforcement(ctx, pipe, gsKey, ".stationedGuards", hostId, r)
+			if err != nil {
+				return err
+			}
+		}
+		for ownerId, r := range p.gsPatch.Reinforcements {
+			err = internal.SaveReinforcement(ctx, pipe, gsKey, ".reinforcements", ownerId, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Write injuries
+		for id, injury := range p.gsPatch.Injuries {
+			if err = internal.SaveInjury(ctx, pipe, gsKey, id, injury); err != nil {
+				return err
+			}
+		}
+		for _, id := range p.gsPatch.RecoveredInjuries {
+			if err = internal.RemoveInjury(ctx, pipe, gsKey, id); err != nil {
+				return err
+			}
+		}
+
+		// Write experience
+		if p.gsPatch.ThiefExperience != nil {
+			err = internal.RedisJsonSet(
+				pipe, ctx, gsKey, ".thiefExperience",
+				p.gsPatch.ThiefExperience.Value).Err()
+			if err != nil {
+				return fmt.Errorf("failed to write thief experience: %w", err)
+			}
+		}
+		if p.gsPatch.GuardExperience != nil {
+			err = internal.RedisJsonSet(
+				pipe, ctx, gsKey, ".guardExperience",
+				p.gsPatch.GuardExperience.Value).Err()
+			if err != nil {
+				return fmt.Errorf("failed to write guard experience: %w", err)
+			}
+		}
+
+		// Remove incoming travels
+		for _, id := range p.gsPatch.ExpiredIncomingTravels {
+			_, err = internal.RemoveIncomingTravel(ctx, pipe, userId, id)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Write discoveries
 		if p.gsPatch.DiscoveriesPatched {
 			arr := []string{}
@@ -395,6 +516,11 @@ func (u *updater) postProcessPatch(ctx updateContext, userId string, p *patch) e
 		return nil
 	}
 
+	// Nobody plays as an NPC town, so there is nobody to tell
+	if internal.IsNpc(userId) {
+		return nil
+	}
+
 	var err error
 
 	// Send game state patch
@@ -438,6 +564,9 @@ func (u *updater) postProcessPatch(ctx updateContext, userId string, p *patch) e
 func reportsToPipe(ctx updateContext) (pipeFn, error) {
 	return func(pipe redis.Pipeliner) error {
 		for userId, reports := range ctx.reports {
+			if internal.IsNpc(userId) {
+				continue
+			}
 			for _, report := range reports {
 				if err := internal.SaveReport(ctx, pipe, userId, report); err != nil {
 					return fmt.Errorf("failed to save report: %w", err)
@@ -450,7 +579,7 @@ func reportsToPipe(ctx updateContext) (pipeFn, error) {
 }
 
 func addTimeseriesDataPoints(ctx updateContext, r internal.RedisClient) error {
-	if ctx.patch.gsPatch.Timestamp == nil {
+	if ctx.patch.gsPatch.Timestamp == nil || internal.IsNpc(ctx.userId) {
 		return nil
 	}
 
@@ -654,11 +783,19 @@ func main() {
 	rc := internal.NewRedisClient(rdb)
 	world := internal.NewWorldService(rc)
 	leaderboard := internal.NewLeaderboardService(rc)
-	u := updater{r: rc, world: world, leaderboard: leaderboard}
+	market := internal.NewMarketService(rc)
+	u := updater{r: rc, world: world, leaderboard: leaderboard, market: market}
 
 	ctx := context.Background()
 
+	lastMarketExpiry := time.Time{}
+
 	for {
+		if time.Since(lastMarketExpiry) > 1*time.Second {
+			u.expireMarketOrders(ctx)
+			lastMarketExpiry = time.Now()
+		}
+
 		userId, err := u.next(ctx)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to get next")
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Expires market orders that have been open for too long. The resources
+// held in escrow are returned by the market service, so the owners only
+// need to be sent their new reports.
+func (u *updater) expireMarketOrders(ctx context.Context) {
+	affected, err := u.market.ExpireOrders(ctx, time.Now().UnixNano())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to expire market orders")
+	}
+
+	sent := map[string]bool{}
+	for _, userId := range affected {
+		if sent[userId] {
+			continue
+		}
+		sent[userId] = true
+		if err = sendReports(ctx, u.r, userId); err != nil {
+			log.Error().Err(err).Msg("Failed to send reports")
+		}
+	}
+}
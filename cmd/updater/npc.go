@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
+)
+
+// Lets NPC towns act like players. They train mice to get back to their
+// population, and bandits go raiding nearby player towns.
+func updateNpc(ctx updateContext, r internal.RedisClient, world *internal.WorldService) error {
+	if !internal.IsNpc(ctx.userId) || ctx.gs.Npc == nil {
+		return nil
+	}
+
+	if err := trainNpc(ctx); err != nil {
+		return fmt.Errorf("failed to train npc: %w", err)
+	}
+
+	if ctx.gs.Npc.Kind == models.Npc_BANDIT {
+		if err := raidWithNpc(ctx, r, world); err != nil {
+			return fmt.Errorf("failed to raid with npc: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func trainNpc(ctx updateContext) error {
+	// Completed trainings might already have been removed from the queue
+	if ctx.patch.gsPatch.TrainingQueuePatched {
+		ctx.gs.TrainingQueue = ctx.patch.gsPatch.TrainingQueue
+	}
+
+	edu, amount, ok := internal.GetNpcTraining(ctx.gs)
+	if !ok {
+		return nil
+	}
+	capacity, speed := internal.CountTrainingCapacity(ctx.gs)
+
+	batches := internal.ScheduleTrainings(
+		ctx.gs.TrainingQueue, capacity, speed,
+		edu, amount, time.Now().UnixNano())
+
+	cost := internal.FullGameData.Educations[int32(edu)].Cost * amount
+	ctx.IncrUneducated(-amount)
+	ctx.IncrCoins(-cost)
+
+	queue := append([]*models.Training{}, ctx.gs.TrainingQueue...)
+	ctx.gs.TrainingQueue = append(queue, batches...)
+	ctx.patch.gsPatch.TrainingQueue = ctx.gs.TrainingQueue
+	ctx.patch.gsPatch.TrainingQueuePatched = true
+
+	return nil
+}
+
+func raidWithNpc(ctx updateContext, r internal.RedisClient, world *internal.WorldService) error {
+	queue := ctx.gs.TravelQueue
+	if ctx.patch.gsPatch.TravelQueuePatched {
+		queue = ctx.patch.gsPatch.TravelQueue
+	}
+
+	// Bandits only go on one raid at a time
+	if len(queue) > 0 || ctx.gs.Population.Thieves < internal.NpcRaidMinThieves {
+		return nil
+	}
+
+	// Roll whether to raid since the last update
+	now := time.Now()
+	dt := now.Unix() - ctx.gs.Timestamp
+	if dt <= 0 {
+		return nil
+	}
+	worldSeed, err := internal.GetWorldSeed(ctx, r)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(internal.DeriveSeed(
+		worldSeed, "raid", ctx.userId, strconv.FormatInt(ctx.gs.Timestamp, 10))))
+	if rnd.Float64() >= float64(dt)/internal.NpcRaidInterval.Seconds() {
+		return nil
+	}
+
+	// Pick a nearby player town
+	towns, err := world.GetTownsInRange(ctx, int(ctx.gs.TownX), int(ctx.gs.TownY), internal.NpcRaidRange)
+	if err != nil {
+		return err
+	}
+	targets := []internal.TownLocation{}
+	for _, town := range towns {
+		if !internal.IsNpc(town.UserId) {
+			targets = append(targets, town)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	target := targets[rnd.Intn(len(targets))]
+
+	gsTarget := &models.GameState{}
+	s, err := internal.RedisJsonGet(r, ctx, fmt.Sprintf("user:%s:gamestate", target.UserId), ".").Result()
+	if err != nil {
+		return err
+	}
+	if err = protojson.Unmarshal([]byte(s), gsTarget); err != nil {
+		return err
+	}
+
+	// Bandits leave new players and recently robbed towns alone, just
+	// like players have to
+	err = internal.CheckCanSteal(ctx, r, ctx.userId, target.UserId, gsTarget)
+	if err == internal.ErrTargetProtected || err == internal.ErrStealCooldown {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	arrivalAt, err := world.CalculateArrivalTime(ctx,
+		ctx.gs.TownX, ctx.gs.TownY,
+		int32(target.X), int32(target.Y),
+		internal.ThiefSpeed)
+	if err == internal.ErrNoPath {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	thieves := ctx.gs.Population.Thieves / 2
+	travel := &models.Travel{
+		Id:           xid.New().String(),
+		DepartureAt:  now.UnixNano(),
+		ArrivalAt:    arrivalAt,
+		DestinationX: int32(target.X),
+		DestinationY: int32(target.Y),
+		Thieves:      thieves,
+		Resource:     models.Resource_COINS,
+	}
+
+	ctx.IncrThieves(-thieves)
+	ctx.gs.TravelQueue = append(queue, travel)
+	ctx.patch.gsPatch.TravelQueue = ctx.gs.TravelQueue
+	ctx.patch.gsPatch.TravelQueuePatched = true
+
+	log.Info().
+		Str("userId", ctx.userId).
+		Str("targetId", target.UserId).
+		Int32("thieves", thieves).
+		Msg("Npc raid sent")
+
+	// The guards of the target might spot the bandits, just like they can
+	// spot the thieves of players
+	chance := internal.GetDetectionChance(internal.CountDefendingGuards(gsTarget), thieves)
+	if rnd.Float64() >= chance {
+		return nil
+	}
+	incoming := &models.IncomingTravel{
+		Id:        travel.Id,
+		ArrivalAt: travel.ArrivalAt,
+		FromX:     ctx.gs.TownX,
+		FromY:     ctx.gs.TownY,
+		Thieves:   thieves,
+	}
+	if err = internal.AddIncomingTravel(ctx, r, target.UserId, incoming); err != nil {
+		return err
+	}
+	err = send(ctx, r, target.UserId, &models.ServerMessage{
+		Id: xid.New().String(),
+		Payload: &models.ServerMessage_IncomingTravel{
+			IncomingTravel: incoming,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send incoming travel: %w", err)
+	}
+
+	return nil
+}
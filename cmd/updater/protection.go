@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/rs/xid"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const turnedAwayReportTemplateText = `
+Our {{ .Thieves }} thieves arrived at {{ .TargetUsername }}'s town, but could not steal anything since {{ .Reason }}. They are on their way back home.
+`
+
+var turnedAwayReportTemplate *template.Template
+
+type turnedAwayReportTemplateData struct {
+	TargetUsername string
+	Thieves        int32
+	Reason         string
+}
+
+func init() {
+	turnedAwayReportTemplate = template.Must(template.New("root").
+		Parse(turnedAwayReportTemplateText))
+}
+
+// Ends the beginner protection when it runs out, or when the town has grown
+// past the protection thresholds
+func endBeginnerProtection(ctx updateContext) error {
+	if ctx.gs.ProtectedUntil == 0 {
+		return nil
+	}
+
+	if ctx.gs.ProtectedUntil > time.Now().UnixNano() &&
+		!internal.HasOutgrownBeginnerProtection(ctx.gs) {
+		return nil
+	}
+
+	ctx.gs.ProtectedUntil = 0
+	ctx.patch.gsPatch.ProtectedUntil = &wrapperspb.Int64Value{Value: 0}
+
+	return nil
+}
+
+// Sends thieves back home without stealing anything, because the target
+// could no longer be robbed when they arrived
+func turnAwayThieves(ctx updateContext, world *internal.WorldService, travel *models.Travel, targetUsername string, reason error) error {
+	arrivalAt, err := world.CalculateArrivalTime(ctx,
+		travel.DestinationX, travel.DestinationY,
+		ctx.gs.TownX, ctx.gs.TownY,
+		internal.ThiefSpeed,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to turn away thieves: %w", err)
+	}
+	ctx.patch.gsPatch.TravelQueue = append(ctx.patch.gsPatch.TravelQueue, &models.Travel{
+		ArrivalAt:    arrivalAt,
+		DestinationX: travel.DestinationX,
+		DestinationY: travel.DestinationY,
+		Returning:    true,
+		Thieves:      travel.Thieves,
+	})
+
+	tmplData := turnedAwayReportTemplateData{
+		TargetUsername: targetUsername,
+		Thieves:        travel.Thieves,
+		Reason:         "we robbed it recently",
+	}
+	if reason == internal.ErrTargetProtected {
+		tmplData.Reason = "it is under beginner protection"
+	}
+
+	buf := new(bytes.Buffer)
+	if err := turnedAwayReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get thief report contents: %w", err)
+	}
+	ctx.AppendReport(ctx.userId, &models.Report{
+		Id:        xid.New().String(),
+		CreatedAt: time.Now().UnixNano(),
+		Title:     "Thief report",
+		Content:   buf.String(),
+		Unread:    true,
+	})
+
+	return nil
+}
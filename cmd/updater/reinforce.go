@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+const reinforceReportTemplateText = `
+{{if .TurnedAway}}
+Our {{ .Guards }} guards were turned away from {{ .HostUsername }}'s town, since we are no longer in the same tribe. They are on their way back home.
+{{- else}}
+Our {{ .Guards }} guards have arrived in {{ .HostUsername }}'s town, and will help defend it until we recall them.
+{{- end}}
+`
+const reinforceHostReportTemplateText = `
+{{ .Guards }} guards from {{ .OwnerUsername }} have arrived in our town, and will help defend it.
+`
+const defenceReportTemplateText = `
+Our {{ .Guards }} guards stationed in {{ .HostUsername }}'s town helped defend it against {{ .Attackers }} {{ .AttackerKind }}.
+{{if gt .Caught 0}}
+{{ .Caught }} of the {{ .AttackerKind }} were caught.
+{{- else}}
+None of the {{ .AttackerKind }} were caught.
+{{- end}}
+`
+
+var reinforceReportTemplate *template.Template
+var reinforceHostReportTemplate *template.Template
+var defenceReportTemplate *template.Template
+
+type reinforceReportTemplateData struct {
+	OwnerUsername string
+	HostUsername  string
+	Guards        int32
+	TurnedAway    bool
+}
+
+type defenceReportTemplateData struct {
+	HostUsername string
+	Guards       int32
+	Attackers    int32
+	AttackerKind string
+	Caught       int32
+}
+
+func init() {
+	reinforceReportTemplate = template.Must(template.New("root").
+		Parse(reinforceReportTemplateText))
+
+	reinforceHostReportTemplate = template.Must(template.New("root").
+		Parse(reinforceHostReportTemplateText))
+
+	defenceReportTemplate = template.Must(template.New("root").
+		Parse(defenceReportTemplateText))
+}
+
+func completeReinforce(ctx updateContext, r internal.RedisClient, world *internal.WorldService, travel *models.Travel) error {
+	x := travel.DestinationX
+	y := travel.DestinationY
+
+	// Validate host town
+	worldEntry, err := world.GetEntryXY(ctx, int(x), int(y))
+	if err != nil {
+		return fmt.Errorf("could not find world entry: %w", err)
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", x, y)
+	}
+	if town.UserId == ctx.userId {
+		return errors.New("can't reinforce own town")
+	}
+
+	tmplData := reinforceReportTemplateData{Guards: travel.Guards}
+	if tmplData.OwnerUsername, err = getUsername(ctx, r, ctx.userId); err != nil {
+		return fmt.Errorf("failed to complete reinforce: %w", err)
+	}
+	if tmplData.HostUsername, err = getUsername(ctx, r, town.UserId); err != nil {
+		return fmt.Errorf("failed to complete reinforce: %w", err)
+	}
+
+	// The users might have left the tribe while the guards were on their
+	// way, in which case the guards return home.
+	allies, err := internal.NewTribeService(r).AreAllies(ctx, ctx.userId, town.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to complete reinforce: %w", err)
+	}
+	tmplData.TurnedAway = !allies
+
+	if allies {
+		guards := travel.Guards
+		if stationed := ctx.gs.StationedGuards[town.UserId]; stationed != nil {
+			guards = guards + stationed.Guards
+		}
+
+		stationed := &models.Reinforcement{
+			UserId: town.UserId,
+			TownX:  x,
+			TownY:  y,
+			Guards: guards,
+		}
+		if ctx.gs.StationedGuards == nil {
+			ctx.gs.StationedGuards = map[string]*models.Reinforcement{}
+		}
+		ctx.gs.StationedGuards[town.UserId] = stationed
+		if ctx.patch.gsPatch.StationedGuards == nil {
+			ctx.patch.gsPatch.StationedGuards = map[string]*models.Reinforcement{}
+		}
+		ctx.patch.gsPatch.StationedGuards[town.UserId] = stationed
+
+		// The upkeep of the guards changes the stats of the owner
+		ctx.patch.sendStats = true
+
+		ctx.initPatch(town.UserId)
+		hostPatch := ctx.patches[town.UserId]
+		if hostPatch.gsPatch.Reinforcements == nil {
+			hostPatch.gsPatch.Reinforcements = map[string]*models.Reinforcement{}
+		}
+		hostPatch.gsPatch.Reinforcements[ctx.userId] = &models.Reinforcement{
+			UserId: ctx.userId,
+			TownX:  ctx.gs.TownX,
+			TownY:  ctx.gs.TownY,
+			Guards: guards,
+		}
+	} else {
+		arrivalAt, err := world.CalculateArrivalTime(ctx,
+			x, y,
+			ctx.gs.TownX, ctx.gs.TownY,
+			internal.GuardSpeed,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to complete reinforce: %w", err)
+		}
+		ctx.patch.gsPatch.TravelQueue = append(ctx.patch.gsPatch.TravelQueue, &models.Travel{
+			ArrivalAt:    arrivalAt,
+			DestinationX: x,
+			DestinationY: y,
+			Returning:    true,
+			Guards:       travel.Guards,
+		})
+	}
+
+	// Build reports
+	buf := new(bytes.Buffer)
+	if err = reinforceReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get reinforce report contents: %w", err)
+	}
+	ctx.AppendReport(ctx.userId, &models.Report{
+		Id:        xid.New().String(),
+		CreatedAt: time.Now().UnixNano(),
+		Title:     "Reinforcement report",
+		Content:   buf.String(),
+		Unread:    true,
+	})
+
+	if allies {
+		buf = new(bytes.Buffer)
+		if err = reinforceHostReportTemplate.Execute(buf, &tmplData); err != nil {
+			return fmt.Errorf("failed to get reinforce report contents: %w", err)
+		}
+		ctx.AppendReport(town.UserId, &models.Report{
+			Id:        xid.New().String(),
+			CreatedAt: time.Now().UnixNano(),
+			Title:     "Reinforcements have arrived",
+			Content:   buf.String(),
+			Unread:    true,
+		})
+	}
+
+	return nil
+}
+
+func completeReinforceReturn(ctx updateContext, travel *models.Travel) error {
+	ctx.IncrGuards(travel.Guards)
+
+	log.Info().
+		Str("userId", ctx.userId).
+		Int32("guards", travel.Guards).
+		Msg("Reinforce return completed")
+
+	return nil
+}
+
+// Sends the guards stationed in other towns home when the owner has run out
+// of coins, since their upkeep can no longer be paid
+func recallUnpaidReinforcements(ctx updateContext, world *internal.WorldService) error {
+	if ctx.gs.Resources.Coins > 0 || internal.CountStationedGuards(ctx.gs) == 0 {
+		return nil
+	}
+
+	queue := ctx.gs.TravelQueue
+	if ctx.patch.gsPatch.TravelQueuePatched {
+		queue = ctx.patch.gsPatch.TravelQueue
+	}
+	if ctx.patch.gsPatch.StationedGuards == nil {
+		ctx.patch.gsPatch.StationedGuards = map[string]*models.Reinforcement{}
+	}
+
+	// The hosts are sorted so that the travels are added in the same order
+	// every time
+	hostIds := make([]string, 0, len(ctx.gs.StationedGuards))
+	for hostId := range ctx.gs.StationedGuards {
+		hostIds = append(hostIds, hostId)
+	}
+	sort.Strings(hostIds)
+
+	for _, hostId := range hostIds {
+		stationed := ctx.gs.StationedGuards[hostId]
+		if stationed.Guards <= 0 {
+			continue
+		}
+
+		arrivalAt, err := world.CalculateArrivalTime(ctx,
+			stationed.TownX, stationed.TownY,
+			ctx.gs.TownX, ctx.gs.TownY,
+			internal.GuardSpeed,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to recall unpaid reinforcements: %w", err)
+		}
+		queue = append(queue, &models.Travel{
+			ArrivalAt:    arrivalAt,
+			DestinationX: stationed.TownX,
+			DestinationY: stationed.TownY,
+			Returning:    true,
+			Guards:       stationed.Guards,
+		})
+
+		// Reinforcements without guards are removed
+		ctx.patch.gsPatch.StationedGuards[hostId] = &models.Reinforcement{}
+		ctx.initPatch(hostId)
+		hostPatch := ctx.patches[hostId]
+		if hostPatch.gsPatch.Reinforcements == nil {
+			hostPatch.gsPatch.Reinforcements = map[string]*models.Reinforcement{}
+		}
+		hostPatch.gsPatch.Reinforcements[ctx.userId] = &models.Reinforcement{}
+		delete(ctx.gs.StationedGuards, hostId)
+
+		log.Info().
+			Str("userId", ctx.userId).
+			Str("hostId", hostId).
+			Int32("guards", stationed.Guards).
+			Msg("Recalled reinforcements that could not be paid for")
+	}
+
+	internal.SortTravelQueue(queue)
+	ctx.gs.TravelQueue = queue
+	ctx.patch.gsPatch.TravelQueue = queue
+	ctx.patch.gsPatch.TravelQueuePatched = true
+
+	// The upkeep is gone
+	ctx.patch.sendStats = true
+
+	return nil
+}
+
+// Lets the owners of guards stationed in the target town know that their
+// guards took part in defending it
+func appendDefenceReports(ctx updateContext, gsTarget *models.GameState, data defenceReportTemplateData) error {
+	for ownerId, reinforcement := range gsTarget.Reinforcements {
+		if reinforcement.Guards <= 0 {
+			continue
+		}
+
+		data.Guards = reinforcement.Guards
+		buf := new(bytes.Buffer)
+		if err := defenceReportTemplate.Execute(buf, &data); err != nil {
+			return fmt.Errorf("failed to get defence report contents: %w", err)
+		}
+
+		ctx.initPatch(ownerId)
+		ctx.AppendReport(ownerId, &models.Report{
+			Id:        xid.New().String(),
+			CreatedAt: time.Now().UnixNano(),
+			Title:     "Our guards have fought",
+			Content:   buf.String(),
+			Unread:    true,
+		})
+	}
+
+	return nil
+}
+
+func hasReinforcementEntries(m map[string]*models.Reinforcement) bool {
+	for _, r := range m {
+		if r.Guards > 0 {
+			return true
+		}
+	}
+	return false
+}
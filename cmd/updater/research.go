@@ -3,11 +3,14 @@ package main
 import (
 	"time"
 
-	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal"
 )
 
 func completeResearchs(ctx updateContext) error {
-	completedResearchs := getCompletedResearchs(ctx.gs)
+	// Research can run in parallel slots, so any item in the queue can
+	// be completed regardless of its position.
+	completedResearchs, ongoingResearchs := internal.SplitCompletedResearchs(
+		ctx.gs.ResearchQueue, time.Now().UnixNano())
 
 	// Exit early if there are no completed researchs
 	if len(completedResearchs) == 0 {
@@ -15,7 +18,7 @@ func completeResearchs(ctx updateContext) error {
 	}
 
 	// Update patch
-	ctx.patch.gsPatch.ResearchQueue = ctx.gs.ResearchQueue[len(completedResearchs):]
+	ctx.patch.gsPatch.ResearchQueue = ongoingResearchs
 	ctx.patch.gsPatch.ResearchQueuePatched = true
 
 	if ctx.patch.gsPatch.Discoveries == nil {
@@ -35,17 +38,3 @@ func completeResearchs(ctx updateContext) error {
 
 	return nil
 }
-
-func getCompletedResearchs(gs *models.GameState) (res []*models.OngoingResearch) {
-	now := time.Now().UnixNano()
-
-	for _, t := range gs.ResearchQueue {
-		if t.CompleteAt > now {
-			break
-		}
-
-		res = append(res, t)
-	}
-
-	return res
-}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// A sabotaged building is disabled for a base duration and then some
+// additional time for every saboteur that got through.
+const sabotageBaseDuration = 1 * time.Hour
+const sabotageDurationPerSaboteur = 15 * time.Minute
+const sabotageMaxDuration = 8 * time.Hour
+
+const saboteurReportTemplateText = `
+{{if gt .SuccessfulSaboteurs 0}}
+{{if not .Building}}
+Our saboteurs got into {{ .TargetUsername }}'s town, but could not find anything to damage.
+{{- else}}
+Our sabotage with {{ .Saboteurs }} saboteurs on {{ .TargetUsername }}'s town was successful.
+{{if .Downgraded}}
+They damaged a {{ .Building }} so badly that it lost a level.
+{{- else}}
+They disabled a {{ .Building }} for {{ .Duration }}.
+{{- end}}
+{{- end}}
+{{if gt .CaughtSaboteurs 0}}
+{{ .CaughtSaboteurs }} saboteurs were caught.
+{{- end}}
+{{- else}}
+Our sabotage on {{ .TargetUsername }} was a failure. All {{ .Saboteurs }} saboteurs got caught.
+{{- end}}
+`
+const saboteurTargetReportTemplateText = `
+{{if eq .SuccessfulSaboteurs 0}}
+{{ .CaughtSaboteurs }} saboteurs were caught trying to damage our town.
+{{- else if not .Building}}
+Saboteurs sneaked into our town, but could not find anything to damage.
+{{- else}}
+Saboteurs have damaged one of our buildings!
+{{if .Downgraded}}
+Our {{ .Building }} lost a level.
+{{- else}}
+Our {{ .Building }} will not work for {{ .Duration }}, unless we repair it.
+{{- end}}
+{{if gt .CaughtSaboteurs 0}}
+We managed to catch {{ .CaughtSaboteurs }} of the saboteurs.
+{{- end}}
+{{- end}}
+`
+
+var saboteurReportTemplate *template.Template
+var saboteurTargetReportTemplate *template.Template
+
+type sabotageReportTemplateData struct {
+	TargetUsername      string
+	Saboteurs           int32
+	SuccessfulSaboteurs int32
+	CaughtSaboteurs     int32
+	Building            string
+	Downgraded          bool
+	Duration            time.Duration
+}
+
+func init() {
+	saboteurReportTemplate = template.Must(template.New("root").
+		Parse(saboteurReportTemplateText))
+
+	saboteurTargetReportTemplate = template.Must(template.New("root").
+		Parse(saboteurTargetReportTemplateText))
+}
+
+func completeSabotage(ctx updateContext, r internal.RedisClient, world *internal.WorldService, travel *models.Travel) error {
+	gsTarget := &models.GameState{}
+	x := travel.DestinationX
+	y := travel.DestinationY
+
+	// Validate target town
+	worldEntry, err := world.GetEntryXY(ctx, int(x), int(y))
+	if err != nil {
+		return fmt.Errorf("could not find world entry: %w", err)
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", x, y)
+	}
+	if town.UserId == ctx.userId {
+		return errors.New("can't sabotage own town")
+	}
+
+	// Get game state of target
+	gsKeyTarget := fmt.Sprintf("user:%s:gamestate", town.UserId)
+	s, err := internal.RedisJsonGet(r, ctx, gsKeyTarget, ".").Result()
+	if err != nil {
+		return fmt.Errorf("failed to complete sabotage: %w", err)
+	}
+	if err = protojson.Unmarshal([]byte(s), gsTarget); err != nil {
+		return fmt.Errorf("failed to complete sabotage: %w", err)
+	}
+	if gsTarget.Population == nil {
+		gsTarget.Population = &models.GameState_Population{}
+	}
+
+	// Get username of target
+	targetUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", town.UserId), "username").Result()
+	if err != nil {
+		return fmt.Errorf("failed to complete sabotage: %w", err)
+	}
+
+	// Calculate outcome
+	seed, err := getTravelSeed(ctx, r, "sabotage", travel)
+	if err != nil {
+		return fmt.Errorf("failed to complete sabotage: %w", err)
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	guards := float64(internal.CountDefendingGuards(gsTarget))
+	saboteurs := float64(travel.Saboteurs)
+	dist := distuv.Binomial{
+		N:   saboteurs,
+		P:   saboteurs / (saboteurs + guards/2),
+		Src: rnd,
+	}
+	successfulSaboteurs := int32(dist.Rand())
+	caughtSaboteurs := travel.Saboteurs - successfulSaboteurs
+
+	tmplData := sabotageReportTemplateData{
+		TargetUsername:      targetUsername,
+		Saboteurs:           travel.Saboteurs,
+		SuccessfulSaboteurs: successfulSaboteurs,
+		CaughtSaboteurs:     caughtSaboteurs,
+	}
+
+	// Pick a random building to damage, among those that are affected by
+	// it. The lot ids are sorted so that the pick only depends on the
+	// random source.
+	lotIds := make([]string, 0, len(gsTarget.Lots))
+	for lotId, lot := range gsTarget.Lots {
+		if internal.CanSabotageLot(lot) {
+			lotIds = append(lotIds, lotId)
+		}
+	}
+	sort.Strings(lotIds)
+
+	if successfulSaboteurs > 0 && len(lotIds) > 0 {
+		lotId := lotIds[rnd.Intn(len(lotIds))]
+		lot := gsTarget.Lots[lotId]
+		buildingInfo := internal.FullGameData.Buildings[int32(lot.Building)]
+		levelInfo := buildingInfo.LevelInfos[lot.Level]
+
+		lotPatch := &models.GameStatePatch_LotPatch{
+			Building:      lot.Building,
+			TappedAt:      lot.TappedAt,
+			Level:         lot.Level,
+			DisabledUntil: lot.DisabledUntil,
+		}
+
+		// Employers are disabled for a while, so that the workers can
+		// not do their job. Other buildings lose a level instead, if
+		// they have been upgraded.
+		if levelInfo.Employer == nil && lot.Level > 0 {
+			lotPatch.Level = lot.Level - 1
+			tmplData.Downgraded = true
+
+			// Mice that no longer have a bed leave the town, like
+			// when a house is razed
+			if levelInfo.Residence != nil {
+				lostBeds := levelInfo.Residence.Beds -
+					buildingInfo.LevelInfos[lotPatch.Level].Residence.Beds
+				ctx.forUser(town.UserId, gsTarget).EvictPopulation(lostBeds)
+			}
+		} else {
+			duration := sabotageBaseDuration +
+				time.Duration(successfulSaboteurs)*sabotageDurationPerSaboteur
+			if duration > sabotageMaxDuration {
+				duration = sabotageMaxDuration
+			}
+			lotPatch.DisabledUntil = internal.Max(
+				lot.DisabledUntil,
+				time.Now().Add(duration).UnixNano())
+			tmplData.Duration = duration
+		}
+		tmplData.Building = buildingInfo.Title
+
+		ctx.initPatch(town.UserId)
+		targetPatch := ctx.patches[town.UserId]
+		if targetPatch.gsPatch.Lots == nil {
+			targetPatch.gsPatch.Lots = map[string]*models.GameStatePatch_LotPatch{}
+		}
+		targetPatch.gsPatch.Lots[lotId] = lotPatch
+
+		// Losing a building affects the stats of the target
+		targetPatch.sendStats = true
+	}
+
+	// Prepare return travel - but not if all saboteurs got caught
+	if successfulSaboteurs > 0 {
+		arrivalAt, err := world.CalculateArrivalTime(ctx,
+			travel.DestinationX, travel.DestinationY,
+			ctx.gs.TownX, ctx.gs.TownY,
+			internal.SaboteurSpeed,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to complete sabotage: %w", err)
+		}
+
+		ctx.patch.gsPatch.TravelQueue = append(ctx.patch.gsPatch.TravelQueue, &models.Travel{
+			ArrivalAt:    arrivalAt,
+			DestinationX: travel.DestinationX,
+			DestinationY: travel.DestinationY,
+			Returning:    true,
+			Saboteurs:    successfulSaboteurs,
+		})
+	}
+
+	// Build reports
+	buf := new(bytes.Buffer)
+	if err = saboteurReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get saboteur report contents: %w", err)
+	}
+	saboteurReport := &models.Report{
+		Id:        xid.New().String(),
+		CreatedAt: time.Now().UnixNano(),
+		Title:     "Sabotage report",
+		Content:   buf.String(),
+		Unread:    true,
+		Seed:      seed,
+	}
+	buf = new(bytes.Buffer)
+	if err = saboteurTargetReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get target report contents: %w", err)
+	}
+	targetReportTitle := "We have been sabotaged!"
+	if successfulSaboteurs == 0 {
+		targetReportTitle = "We caught saboteurs!"
+	}
+	targetReport := &models.Report{
+		Id:        xid.New().String(),
+		CreatedAt: time.Now().UnixNano(),
+		Title:     targetReportTitle,
+		Content:   buf.String(),
+		Unread:    true,
+		Seed:      seed,
+	}
+
+	ctx.initPatch(town.UserId)
+	ctx.AppendReport(ctx.userId, saboteurReport)
+	ctx.AppendReport(town.UserId, targetReport)
+
+	err = appendDefenceReports(ctx, gsTarget, defenceReportTemplateData{
+		HostUsername: targetUsername,
+		Attackers:    travel.Saboteurs,
+		AttackerKind: "saboteurs",
+		Caught:       caughtSaboteurs,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func completeSabotageReturn(ctx updateContext, travel *models.Travel) error {
+	ctx.IncrSaboteurs(travel.Saboteurs)
+
+	log.Info().
+		Str("userId", ctx.userId).
+		Int32("saboteurs", travel.Saboteurs).
+		Msg("Sabotage return completed")
+
+	return nil
+}
+
+// Clears the disabled state of buildings that have been repaired over time,
+// so that the stats of the town are sent to the client again.
+func completeRepairs(ctx updateContext) error {
+	now := time.Now().UnixNano()
+
+	for lotId, lot := range ctx.gs.Lots {
+		if lot.DisabledUntil == 0 || internal.IsLotDisabled(lot, now) {
+			continue
+		}
+
+		// Constructions might have patched the lot already
+		if ctx.patch.gsPatch.Lots == nil {
+			ctx.patch.gsPatch.Lots = map[string]*models.GameStatePatch_LotPatch{}
+		}
+		if ctx.patch.gsPatch.Lots[lotId] != nil {
+			continue
+		}
+
+		ctx.patch.gsPatch.Lots[lotId] = &models.GameStatePatch_LotPatch{
+			Building: lot.Building,
+			TappedAt: lot.TappedAt,
+			Level:    lot.Level,
+		}
+		ctx.patch.sendStats = true
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+const scoutReportTemplateText = `
+{{if gt .SuccessfulScouts 0}}
+Our scouts have returned from {{ .TargetUsername }}'s town.
+{{if gt .CaughtScouts 0}}
+{{ .CaughtScouts }} scouts were caught, so the town has been warned about us.
+{{- end}}
+The town is guarded by {{ .Report.GuardsMin | mprintf "%d" }} to {{ .Report.GuardsMax | mprintf "%d" }} guards and has {{ .Report.CoinsMin | mprintf "%d" }} to {{ .Report.CoinsMax | mprintf "%d" }} coins.
+{{range .Buildings}}
+{{ .Min }} to {{ .Max }} {{ .Title }}
+{{- end}}
+{{- else}}
+Our scouting mission on {{ .TargetUsername }} was a failure. All {{ .Scouts }} scouts got caught.
+{{- end}}
+`
+const scoutTargetReportTemplateText = `
+{{ .CaughtScouts }} scouts from {{ .ScoutUsername }} were caught spying on our town.
+`
+
+var scoutReportTemplate *template.Template
+var scoutTargetReportTemplate *template.Template
+
+type scoutReportBuilding struct {
+	Title string
+	Min   int32
+	Max   int32
+}
+
+type scoutReportTemplateData struct {
+	TargetUsername   string
+	ScoutUsername    string
+	Scouts           int32
+	SuccessfulScouts int32
+	CaughtScouts     int32
+	Report           *models.ScoutReport
+	Buildings        []scoutReportBuilding
+}
+
+func init() {
+	tmplFuncMap := template.FuncMap{
+		"mprintf": messagePrinter.Sprintf,
+	}
+
+	scoutReportTemplate = template.Must(template.New("root").
+		Funcs(tmplFuncMap).
+		Parse(scoutReportTemplateText))
+
+	scoutTargetReportTemplate = template.Must(template.New("root").
+		Funcs(tmplFuncMap).
+		Parse(scoutTargetReportTemplateText))
+}
+
+// Returns a range that contains the value v. The width of the range is
+// decided by the margin (0 gives the exact value) and the position of v
+// in the range is random, so the real value can not be derived from it.
+func approximate(rnd *rand.Rand, v int64, margin float64) (min, max int64) {
+	u := rnd.Float64()
+	min = int64(math.Floor(float64(v) * (1 - margin*u)))
+	max = int64(math.Ceil(float64(v) * (1 + margin*(1-u))))
+	return min, max
+}
+
+func completeScout(ctx updateContext, r internal.RedisClient, world *internal.WorldService, travel *models.Travel) error {
+	gsTarget := &models.GameState{}
+	x := travel.DestinationX
+	y := travel.DestinationY
+
+	// Validate target town
+	worldEntry, err := world.GetEntryXY(ctx, int(x), int(y))
+	if err != nil {
+		return fmt.Errorf("could not find world entry: %w", err)
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", x, y)
+	}
+	if town.UserId == ctx.userId {
+		return errors.New("can't scout own town")
+	}
+
+	// Get game state of target
+	gsKeyTarget := fmt.Sprintf("user:%s:gamestate", town.UserId)
+	s, err := internal.RedisJsonGet(r, ctx, gsKeyTarget, ".").Result()
+	if err != nil {
+		return fmt.Errorf("failed to complete scout: %w", err)
+	}
+	if err = protojson.Unmarshal([]byte(s), gsTarget); err != nil {
+		return fmt.Errorf("failed to complete scout: %w", err)
+	}
+	if gsTarget.Population == nil {
+		gsTarget.Population = &models.GameState_Population{}
+	}
+	if gsTarget.Resources == nil {
+		gsTarget.Resources = &models.GameState_Resources{}
+	}
+
+	// Get usernames of both target and scout
+	targetUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", town.UserId), "username").Result()
+	if err != nil {
+		return fmt.Errorf("failed to complete scout: %w", err)
+	}
+	scoutUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", ctx.userId), "username").Result()
+	if err != nil {
+		return fmt.Errorf("failed to complete scout: %w", err)
+	}
+
+	// Calculate outcome. Scouts are harder to catch than thieves.
+	seed, err := getTravelSeed(ctx, r, "scout", travel)
+	if err != nil {
+		return fmt.Errorf("failed to complete scout: %w", err)
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	defendingGuards := internal.CountDefendingGuards(gsTarget)
+	guards := float64(defendingGuards)
+	scouts := float64(travel.Scouts)
+	dist := distuv.Binomial{
+		N:   scouts,
+		P:   scouts / (scouts + guards/4),
+		Src: rnd,
+	}
+	successfulScouts := int32(dist.Rand())
+	caughtScouts := travel.Scouts - successfulScouts
+
+	tmplData := scoutReportTemplateData{
+		TargetUsername:   targetUsername,
+		ScoutUsername:    scoutUsername,
+		Scouts:           travel.Scouts,
+		SuccessfulScouts: successfulScouts,
+		CaughtScouts:     caughtScouts,
+	}
+
+	var scoutReport *models.ScoutReport
+	if successfulScouts > 0 {
+		// The more scouts that got through compared to the number of
+		// guards, the more accurate the report will be.
+		successful := float64(successfulScouts)
+		accuracy := successful / (successful + guards/2)
+		margin := 1 - accuracy
+
+		scoutReport = &models.ScoutReport{
+			TargetUserId: town.UserId,
+			X:            x,
+			Y:            y,
+			Accuracy:     accuracy,
+		}
+
+		guardsMin, guardsMax := approximate(rnd, int64(defendingGuards), margin)
+		scoutReport.GuardsMin = int32(guardsMin)
+		scoutReport.GuardsMax = int32(guardsMax)
+		scoutReport.CoinsMin, scoutReport.CoinsMax = approximate(
+			rnd, int64(gsTarget.Resources.Coins), margin)
+
+		buildingCounts := internal.CountBuildings(gsTarget)
+		buildings := make([]int32, 0, len(buildingCounts))
+		for b := range buildingCounts {
+			buildings = append(buildings, b)
+		}
+		sort.Slice(buildings, func(i, j int) bool { return buildings[i] < buildings[j] })
+		for _, b := range buildings {
+			min, max := approximate(rnd, int64(buildingCounts[b]), margin)
+			scoutReport.Buildings = append(scoutReport.Buildings, &models.ScoutReport_BuildingCount{
+				Building: models.Building(b),
+				Min:      int32(min),
+				Max:      int32(max),
+			})
+			tmplData.Buildings = append(tmplData.Buildings, scoutReportBuilding{
+				Title: internal.FullGameData.Buildings[b].TitlePlural,
+				Min:   int32(min),
+				Max:   int32(max),
+			})
+		}
+
+		tmplData.Report = scoutReport
+
+		// Prepare return travel
+		arrivalAt, err := world.CalculateArrivalTime(ctx,
+			travel.DestinationX, travel.DestinationY,
+			ctx.gs.TownX, ctx.gs.TownY,
+			internal.ScoutSpeed,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to complete scout: %w", err)
+		}
+
+		ctx.patch.gsPatch.TravelQueue = append(ctx.patch.gsPatch.TravelQueue, &models.Travel{
+			ArrivalAt:    arrivalAt,
+			DestinationX: travel.DestinationX,
+			DestinationY: travel.DestinationY,
+			Returning:    true,
+			Scouts:       successfulScouts,
+		})
+	}
+
+	// Build reports
+	buf := new(bytes.Buffer)
+	if err = scoutReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get scout report contents: %w", err)
+	}
+	ctx.AppendReport(ctx.userId, &models.Report{
+		Id:          xid.New().String(),
+		CreatedAt:   time.Now().UnixNano(),
+		Title:       "Scout report",
+		Content:     buf.String(),
+		Unread:      true,
+		ScoutReport: scoutReport,
+		Seed:        seed,
+	})
+
+	// The target is only notified if any scouts were caught
+	if caughtScouts > 0 {
+		buf = new(bytes.Buffer)
+		if err = scoutTargetReportTemplate.Execute(buf, &tmplData); err != nil {
+			return fmt.Errorf("failed to get scout target report contents: %w", err)
+		}
+		ctx.initPatch(town.UserId)
+		ctx.AppendReport(town.UserId, &models.Report{
+			Id:        xid.New().String(),
+			CreatedAt: time.Now().UnixNano(),
+			Title:     "We caught scouts!",
+			Content:   buf.String(),
+			Unread:    true,
+			Seed:      seed,
+		})
+	}
+
+	return nil
+}
+
+func completeScoutReturn(ctx updateContext, travel *models.Travel) error {
+	ctx.IncrScouts(travel.Scouts)
+
+	log.Info().
+		Str("userId", ctx.userId).
+		Int32("scouts", travel.Scouts).
+		Msg("Scout return completed")
+
+	return nil
+}
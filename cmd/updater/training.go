@@ -3,6 +3,7 @@ package main
 import (
 	"time"
 
+	"github.com/fnatte/pizza-tribes/internal"
 	"github.com/fnatte/pizza-tribes/internal/models"
 )
 
@@ -43,8 +44,19 @@ func completeTrainings(ctx updateContext) error {
 		}}, completions...)
 	}
 
+	// Batches that are waiting for capacity are scheduled against the
+	// current capacity of the schools, which might have changed since
+	// the batches were queued.
+	capacity, _ := internal.CountTrainingCapacity(ctx.gs)
+
 	// Exit early if there are no completed trainings
 	if len(completions) == 0 {
+		queue, changed := internal.RescheduleTrainings(
+			ctx.gs.TrainingQueue, capacity, now)
+		if changed {
+			ctx.patch.gsPatch.TrainingQueue = queue
+			ctx.patch.gsPatch.TrainingQueuePatched = true
+		}
 		return nil
 	}
 
@@ -69,9 +81,16 @@ func completeTrainings(ctx updateContext) error {
 			ctx.IncrThieves(c.amount)
 		case models.Education_PUBLICIST:
 			ctx.IncrPublicists(c.amount)
+		case models.Education_SCOUT:
+			ctx.IncrScouts(c.amount)
+		case models.Education_SABOTEUR:
+			ctx.IncrSaboteurs(c.amount)
 		}
 	}
 
+	ctx.patch.gsPatch.TrainingQueue, _ = internal.RescheduleTrainings(
+		ctx.patch.gsPatch.TrainingQueue, capacity, now)
+
 	// Since we have changed the population we should send a new stats message
 	ctx.patch.sendStats = true
 
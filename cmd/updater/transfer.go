@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const transferSenderReportTemplateText = `
+{{if gt .Intercepted 0}}
+Our couriers were robbed by thieves from {{ .InterceptorUsername }}'s town on their way to {{ .RecipientUsername }}. They lost {{ .Intercepted | mprintf "%d" }} coins, and delivered {{ .Delivered | mprintf "%d" }} coins.
+{{- else}}
+Our couriers delivered {{ .Delivered | mprintf "%d" }} coins to {{ .RecipientUsername }}.
+{{- end}}
+`
+const transferRecipientReportTemplateText = `
+Couriers from {{ .SenderUsername }} delivered {{ .Delivered | mprintf "%d" }} coins to our town.
+{{if gt .Intercepted 0}}
+They told us that thieves had taken {{ .Intercepted | mprintf "%d" }} coins from them on the way.
+{{- end}}
+`
+const transferInterceptorReportTemplateText = `
+Our thieves robbed couriers from {{ .SenderUsername }} on their way to {{ .RecipientUsername }}, and took {{ .Intercepted | mprintf "%d" }} coins.
+`
+
+var transferSenderReportTemplate *template.Template
+var transferRecipientReportTemplate *template.Template
+var transferInterceptorReportTemplate *template.Template
+
+type transferReportTemplateData struct {
+	SenderUsername      string
+	RecipientUsername   string
+	InterceptorUsername string
+	Delivered           int64
+	Intercepted         int64
+}
+
+func init() {
+	tmplFuncMap := template.FuncMap{
+		"mprintf": messagePrinter.Sprintf,
+	}
+
+	transferSenderReportTemplate = template.Must(template.New("root").
+		Funcs(tmplFuncMap).
+		Parse(transferSenderReportTemplateText))
+
+	transferRecipientReportTemplate = template.Must(template.New("root").
+		Funcs(tmplFuncMap).
+		Parse(transferRecipientReportTemplateText))
+
+	transferInterceptorReportTemplate = template.Must(template.New("root").
+		Funcs(tmplFuncMap).
+		Parse(transferInterceptorReportTemplateText))
+}
+
+func getUsername(ctx updateContext, r internal.RedisClient, userId string) (string, error) {
+	return r.HGet(ctx, fmt.Sprintf("user:%s", userId), "username").Result()
+}
+
+// Adds coins to the patch of another user than the one being updated
+func incrOtherUserCoins(ctx updateContext, r internal.RedisClient, userId string, amount int64) error {
+	ctx.initPatch(userId)
+	p := ctx.patches[userId]
+
+	if p.gsPatch.Resources.Coins == nil {
+		gs := &models.GameState{}
+		s, err := internal.RedisJsonGet(r, ctx, fmt.Sprintf("user:%s:gamestate", userId), ".").Result()
+		if err != nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), gs); err != nil {
+			return err
+		}
+		if gs.Resources == nil {
+			gs.Resources = &models.GameState_Resources{}
+		}
+		p.gsPatch.Resources.Coins = &wrapperspb.Int32Value{
+			Value: gs.Resources.Coins,
+		}
+	}
+
+	p.gsPatch.Resources.Coins.Value = p.gsPatch.Resources.Coins.Value + int32(amount)
+
+	return nil
+}
+
+// Finds the town with the most thieves at home along the route of the
+// couriers. Returns an empty user id if there are no thieves on the way.
+func findInterceptor(ctx updateContext, r internal.RedisClient, world *internal.WorldService, travel *models.Travel, recipientId string) (string, int32, error) {
+	var interceptorId string
+	var interceptorThieves int32
+
+	zones := internal.GetRouteZones(ctx.gs.TownX, ctx.gs.TownY, travel.DestinationX, travel.DestinationY)
+	for _, zidx := range zones {
+		zone, err := world.GetZoneIdx(ctx, zidx)
+		if err != nil {
+			return "", 0, err
+		}
+		if zone == nil {
+			continue
+		}
+
+		for _, e := range zone.Entries {
+			town := e.GetTown()
+			if town == nil || town.UserId == ctx.userId || town.UserId == recipientId {
+				continue
+			}
+
+			// JSON.GET fails if the town has never had any thieves, such
+			// towns can not intercept anything
+			s, err := internal.RedisJsonGet(r, ctx,
+				fmt.Sprintf("user:%s:gamestate", town.UserId),
+				".population.thieves").Result()
+			if err == redis.Nil || (err != nil && internal.IsRedisJsonKeyDoesNotExistError(err)) {
+				continue
+			}
+			if err != nil {
+				return "", 0, fmt.Errorf("failed to get thieves: %w", err)
+			}
+			thieves, err := strconv.Atoi(s)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid thieves: %w", err)
+			}
+			if int32(thieves) > interceptorThieves {
+				interceptorId = town.UserId
+				interceptorThieves = int32(thieves)
+			}
+		}
+	}
+
+	return interceptorId, interceptorThieves, nil
+}
+
+func completeTransfer(ctx updateContext, r internal.RedisClient, world *internal.WorldService, travel *models.Travel) error {
+	x := travel.DestinationX
+	y := travel.DestinationY
+
+	// Validate recipient town
+	worldEntry, err := world.GetEntryXY(ctx, int(x), int(y))
+	if err != nil {
+		return fmt.Errorf("could not find world entry: %w", err)
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", x, y)
+	}
+	if town.UserId == ctx.userId {
+		return errors.New("can't transfer coins to own town")
+	}
+
+	tmplData := transferReportTemplateData{}
+	if tmplData.SenderUsername, err = getUsername(ctx, r, ctx.userId); err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	if tmplData.RecipientUsername, err = getUsername(ctx, r, town.UserId); err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+
+	// Thieves along the way might rob the couriers. The more couriers
+	// there are, the harder it is to rob them.
+	interceptorId, thieves, err := findInterceptor(ctx, r, world, travel, town.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	seed, err := getTravelSeed(ctx, r, "transfer", travel)
+	if err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	if interceptorId != "" {
+		rnd := rand.New(rand.NewSource(seed))
+		p := float64(thieves) / float64(thieves+4*travel.Couriers)
+		if rnd.Float64() < p {
+			tmplData.Intercepted = internal.Min(travel.Coins, int64(thieves)*internal.ThiefCapacity)
+			if tmplData.InterceptorUsername, err = getUsername(ctx, r, interceptorId); err != nil {
+				return fmt.Errorf("failed to complete transfer: %w", err)
+			}
+		}
+	}
+	tmplData.Delivered = travel.Coins - tmplData.Intercepted
+
+	if err = incrOtherUserCoins(ctx, r, town.UserId, tmplData.Delivered); err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	if tmplData.Intercepted > 0 {
+		if err = incrOtherUserCoins(ctx, r, interceptorId, tmplData.Intercepted); err != nil {
+			return fmt.Errorf("failed to complete transfer: %w", err)
+		}
+	}
+
+	// Prepare return travel
+	arrivalAt, err := world.CalculateArrivalTime(ctx,
+		travel.DestinationX, travel.DestinationY,
+		ctx.gs.TownX, ctx.gs.TownY,
+		internal.CourierSpeed,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	ctx.patch.gsPatch.TravelQueue = append(ctx.patch.gsPatch.TravelQueue, &models.Travel{
+		ArrivalAt:    arrivalAt,
+		DestinationX: travel.DestinationX,
+		DestinationY: travel.DestinationY,
+		Returning:    true,
+		Couriers:     travel.Couriers,
+	})
+
+	// Build reports
+	buf := new(bytes.Buffer)
+	if err = transferSenderReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get transfer report contents: %w", err)
+	}
+	ctx.AppendReport(ctx.userId, &models.Report{
+		Id:        xid.New().String(),
+		CreatedAt: time.Now().UnixNano(),
+		Title:     "Coins delivered",
+		Content:   buf.String(),
+		Unread:    true,
+		Seed:      seed,
+	})
+
+	buf = new(bytes.Buffer)
+	if err = transferRecipientReportTemplate.Execute(buf, &tmplData); err != nil {
+		return fmt.Errorf("failed to get transfer report contents: %w", err)
+	}
+	ctx.AppendReport(town.UserId, &models.Report{
+		Id:        xid.New().String(),
+		CreatedAt: time.Now().UnixNano(),
+		Title:     "Coins received",
+		Content:   buf.String(),
+		Unread:    true,
+		Seed:      seed,
+	})
+
+	if tmplData.Intercepted > 0 {
+		buf = new(bytes.Buffer)
+		if err = transferInterceptorReportTemplate.Execute(buf, &tmplData); err != nil {
+			return fmt.Errorf("failed to get transfer report contents: %w", err)
+		}
+		ctx.AppendReport(interceptorId, &models.Report{
+			Id:        xid.New().String(),
+			CreatedAt: time.Now().UnixNano(),
+			Title:     "We robbed couriers!",
+			Content:   buf.String(),
+			Unread:    true,
+			Seed:      seed,
+		})
+	}
+
+	return nil
+}
+
+func completeTransferReturn(ctx updateContext, travel *models.Travel) error {
+	// Couriers that were recalled bring the coins back
+	ctx.IncrCoins(int32(travel.Coins))
+	ctx.IncrUneducated(travel.Couriers)
+
+	log.Info().
+		Str("userId", ctx.userId).
+		Int32("couriers", travel.Couriers).
+		Msg("Transfer return completed")
+
+	return nil
+}
@@ -8,14 +8,13 @@ import (
 	"time"
 
 	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/combat"
 	"github.com/fnatte/pizza-tribes/internal/models"
 	"github.com/fnatte/pizza-tribes/internal/protojson"
 	"github.com/go-redis/redis/v8"
 	"github.com/rs/xid"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/exp/rand"
 	"golang.org/x/text/message"
-	"gonum.org/v1/gonum/stat/distuv"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
@@ -25,24 +24,41 @@ const thiefReportTemplateText = `
 {{if gt .SuccessfulThieves 0}}
 Our heist with {{ .Thieves }} thieves on {{ .TargetUsername }}'s town was successful.
 {{if gt .CaughtThieves 0}}
-{{ .CaughtThieves }} thieves were caught, but {{ .SuccessfulThieves }} thieves got away with {{ .Loot | mprintf "%d" }} coins.
+{{ .CaughtThieves }} thieves were caught, but {{ .SuccessfulThieves }} thieves got away with {{ .Loot | mprintf "%d" }} {{ .ResourceName }}.
 {{- else}}
-No thieves were caught, and they got away with {{ .Loot | mprintf "%d" }} coins.
+No thieves were caught, and they got away with {{ .Loot | mprintf "%d" }} {{ .ResourceName }}.
+{{- end}}
+{{if gt .VaultCoins 0}}
+The vault held {{ .VaultCoins | mprintf "%d" }} coins that they could not get to.
+{{- end}}
+{{if gt .InjuredGuards 0}}
+They injured {{ .InjuredGuards }} guards while getting away.
 {{- end}}
 {{- else}}
 Our heist on {{ .TargetUsername }} was a failure. All {{ .Thieves }} thieves got caught.
 {{- end}}
 `
+// The victim is told who sent the caught thieves, so that they know who to
+// write to about it
 const targetReportTemplateText = `
 {{if gt .SuccessfulThieves 0}}
 {{if gt .CaughtThieves 0}}
-{{.CaughtThieves}} thieves were caught trying to steal from our town, but {{ .SuccessfulThieves }} thieves got away with {{ .Loot | mprintf "%d" }} of our coins!
+{{.CaughtThieves}} thieves were caught trying to steal from our town, but {{ .SuccessfulThieves }} thieves got away with {{ .Loot | mprintf "%d" }} of our {{ .ResourceName }}!
 {{- else}}
-It looks like someone stole {{ .Loot | mprintf "%d" }} coins from us.
+It looks like someone stole {{ .Loot | mprintf "%d" }} {{ .ResourceName }} from us.
+{{- end}}
+{{if gt .VaultCoins 0}}
+Our vault kept {{ .VaultCoins | mprintf "%d" }} coins safe.
+{{- end}}
+{{if gt .InjuredGuards 0}}
+{{ .InjuredGuards }} of our guards were injured and need time to recover.
 {{- end}}
 {{- else}}
 {{ .CaughtThieves }} thieves were caught trying to steal from our town.
 {{- end}}
+{{if gt .CaughtThieves 0}}
+The caught thieves confessed that they were sent by {{ .ThiefUsername }}.
+{{- end}}
 `
 
 var thiefReportTemplate *template.Template
@@ -50,10 +66,14 @@ var targetReportTemplate *template.Template
 
 type reportTemplateData struct {
 	TargetUsername    string
+	ThiefUsername     string
 	Loot              int64
+	ResourceName      string
 	Thieves           int32
 	SuccessfulThieves int32
 	CaughtThieves     int32
+	VaultCoins        int32
+	InjuredGuards     int32
 }
 
 type pipeFn func(redis.Pipeliner) error
@@ -72,6 +92,16 @@ func init() {
 		Parse(targetReportTemplateText))
 }
 
+// Returns the seed for the outcome of the travel. It is derived from the
+// world seed so that the outcome can be reproduced.
+func getTravelSeed(ctx updateContext, r internal.RedisClient, kind string, travel *models.Travel) (uint64, error) {
+	worldSeed, err := internal.GetWorldSeed(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	return internal.GetTravelSeed(worldSeed, kind, travel), nil
+}
+
 func completeSteal(ctx updateContext, r internal.RedisClient, world *internal.WorldService, travel *models.Travel, travelIndex int) (error) {
 	gsTarget := &models.GameState{}
 	x := travel.DestinationX
@@ -100,32 +130,68 @@ func completeSteal(ctx updateContext, r internal.RedisClient, world *internal.Wo
 		return fmt.Errorf("failed to complete steal: %w", err)
 	}
 
-	// Get username of target
+	// Get usernames of both target and thief
 	targetUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", town.UserId), "username").Result()
 	if err != nil {
 		return fmt.Errorf("failed to complete steal: %w", err)
 	}
+	thiefUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", ctx.userId), "username").Result()
+	if err != nil {
+		return fmt.Errorf("failed to complete steal: %w", err)
+	}
+
+	// The target might have become protected, or have been robbed by
+	// other thieves of ours, while the thieves were on their way
+	err = internal.CheckCanSteal(ctx, r, ctx.userId, town.UserId, gsTarget)
+	if err == internal.ErrTargetProtected || err == internal.ErrStealCooldown {
+		return turnAwayThieves(ctx, world, travel, targetUsername, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete steal: %w", err)
+	}
+
+	// Calculate outcome. Guards stationed in the town by other users
+	// help defending it.
+	seed, err := getTravelSeed(ctx, r, "steal", travel)
+	if err != nil {
+		return fmt.Errorf("failed to complete steal: %w", err)
+	}
+	outcome := combat.Resolve(combat.Engagement{
+		Attackers:     travel.Thieves,
+		AttackerLevel: combat.GetLevel(ctx.gs.ThiefExperience),
+		Guards:        internal.CountDefendingGuards(gsTarget),
+		GuardLevel:    combat.GetLevel(gsTarget.GuardExperience),
+		Stealth:       internal.ThiefStealth,
+		Seed:          seed,
+	})
+	successfulThieves := outcome.SuccessfulAttackers
+	caughtThieves := outcome.CaughtAttackers
+
+	// Only guards of the town itself are injured. Stationed guards help
+	// to defend the town but are never hurt.
+	var injuredGuards int32 = 0
+	if gsTarget.Population != nil {
+		injuredGuards = internal.MinInt32(outcome.InjuredGuards, gsTarget.Population.Guards)
+	}
 
-	// Calculate outcome
-	guards := float64(gsTarget.Population.Guards)
-	thieves := float64(travel.Thieves)
-	dist := distuv.Binomial{
-		N:   thieves,
-		P:   thieves / (thieves + guards/2),
-		Src: rand.NewSource(uint64(time.Now().UnixNano())),
+	loot := internal.CalculateLoot(gsTarget, travel.Resource, successfulThieves)
+
+	// Coins protected by vaults can not be stolen
+	var vaultCoins int32
+	if travel.Resource == models.Resource_COINS {
+		vaultCoins = internal.MinInt32(internal.CountProtectedCoins(gsTarget), gsTarget.Resources.Coins)
 	}
-	successfulThieves := int32(dist.Rand())
-	caughtThieves := travel.Thieves - successfulThieves
-	maxLoot := successfulThieves * internal.ThiefCapacity
-	loot := int64(internal.MinInt32(maxLoot, gsTarget.Resources.Coins))
 
 	// Prepare return travel - but not if all thieves got caught
 	if successfulThieves > 0 {
-		arrivalAt := internal.CalculateArrivalTime(
+		arrivalAt, err := world.CalculateArrivalTime(ctx,
 			travel.DestinationX, travel.DestinationY,
 			ctx.gs.TownX, ctx.gs.TownY,
 			internal.ThiefSpeed,
 		)
+		if err != nil {
+			return fmt.Errorf("failed to complete steal: %w", err)
+		}
 
 		returnTravel := models.Travel{
 			ArrivalAt:    arrivalAt,
@@ -133,23 +199,36 @@ func completeSteal(ctx updateContext, r internal.RedisClient, world *internal.Wo
 			DestinationY: travel.DestinationY,
 			Returning:    true,
 			Thieves:      successfulThieves,
-			Coins:        loot,
+			Resource:     travel.Resource,
 		}
-		if err != nil {
-			return fmt.Errorf("failed to marshal travel: %w", err)
+		if travel.Resource == models.Resource_PIZZAS {
+			returnTravel.Pizzas = loot
+		} else {
+			returnTravel.Coins = loot
 		}
 
 		// Update patch with return travel
 		ctx.patch.gsPatch.TravelQueue = append(ctx.patch.gsPatch.TravelQueue, &returnTravel)
 	}
 
+	// The same town can not be robbed again by us for a while
+	if successfulThieves > 0 {
+		if err = internal.SetStealCooldown(ctx, r, ctx.userId, town.UserId); err != nil {
+			return fmt.Errorf("failed to complete steal: %w", err)
+		}
+	}
+
 	// Build reports
 	tmplData := reportTemplateData{
 		TargetUsername:    targetUsername,
+		ThiefUsername:     thiefUsername,
 		Loot:              loot,
+		ResourceName:      internal.GetResourceName(travel.Resource),
 		Thieves:           travel.Thieves,
 		SuccessfulThieves: successfulThieves,
 		CaughtThieves:     caughtThieves,
+		VaultCoins:        vaultCoins,
+		InjuredGuards:     injuredGuards,
 	}
 	buf := new(bytes.Buffer)
 	if err = thiefReportTemplate.Execute(buf, &tmplData); err != nil {
@@ -161,6 +240,7 @@ func completeSteal(ctx updateContext, r internal.RedisClient, world *internal.Wo
 		Title:     "Thief report",
 		Content:   buf.String(),
 		Unread:    true,
+		Seed:      seed,
 	}
 	buf = new(bytes.Buffer)
 	if err = targetReportTemplate.Execute(buf, &tmplData); err != nil {
@@ -176,46 +256,102 @@ func completeSteal(ctx updateContext, r internal.RedisClient, world *internal.Wo
 		Title:     targetReportTitle,
 		Content:   buf.String(),
 		Unread:    true,
+		Seed:      seed,
 	}
 
-	// Prepare patch to target user (whoms coins was stoled)
+	// Prepare patch to target user (whoms coins or pizzas was stoled)
 	ctx.initPatch(town.UserId)
-	if ctx.patches[town.UserId].gsPatch.Resources.Coins == nil {
-		ctx.patches[town.UserId].gsPatch.Resources.Coins = &wrapperspb.Int32Value{
-			Value: gsTarget.Resources.Coins,
+	targetResources := ctx.patches[town.UserId].gsPatch.Resources
+	if travel.Resource == models.Resource_PIZZAS {
+		if targetResources.Pizzas == nil {
+			targetResources.Pizzas = &wrapperspb.Int32Value{
+				Value: gsTarget.Resources.Pizzas,
+			}
+		}
+		targetResources.Pizzas.Value = targetResources.Pizzas.Value - int32(loot)
+	} else {
+		if targetResources.Coins == nil {
+			targetResources.Coins = &wrapperspb.Int32Value{
+				Value: gsTarget.Resources.Coins,
+			}
+		}
+		targetResources.Coins.Value = targetResources.Coins.Value - int32(loot)
+	}
+
+	// Injured guards are off duty until they have recovered
+	targetPatch := ctx.patches[town.UserId].gsPatch
+	if injuredGuards > 0 {
+		if targetPatch.Population.Guards == nil {
+			targetPatch.Population.Guards = &wrapperspb.Int32Value{
+				Value: gsTarget.Population.Guards,
+			}
+		}
+		targetPatch.Population.Guards.Value = targetPatch.Population.Guards.Value - injuredGuards
+		if targetPatch.Injuries == nil {
+			targetPatch.Injuries = map[string]*models.Injury{}
+		}
+		targetPatch.Injuries[xid.New().String()] = &models.Injury{
+			Guards:      injuredGuards,
+			RecoveredAt: time.Now().Add(internal.GuardRecoveryTime).UnixNano(),
+		}
+	}
+
+	// Both sides learn from the engagement
+	if outcome.GuardExperience > 0 {
+		if targetPatch.GuardExperience == nil {
+			targetPatch.GuardExperience = &wrapperspb.Int32Value{
+				Value: gsTarget.GuardExperience,
+			}
+		}
+		targetPatch.GuardExperience.Value = targetPatch.GuardExperience.Value + outcome.GuardExperience
+	}
+	if outcome.AttackerExperience > 0 {
+		ctx.gs.ThiefExperience = ctx.gs.ThiefExperience + outcome.AttackerExperience
+		ctx.patch.gsPatch.ThiefExperience = &wrapperspb.Int32Value{
+			Value: ctx.gs.ThiefExperience,
 		}
 	}
-	ctx.patches[town.UserId].gsPatch.Resources.Coins.Value =
-		ctx.patches[town.UserId].gsPatch.Resources.Coins.Value - int32(loot)
 
 	// Append reports to patch
 	ctx.AppendReport(ctx.userId, thiefReport)
 	ctx.AppendReport(town.UserId, targetReport)
 
+	err = appendDefenceReports(ctx, gsTarget, defenceReportTemplateData{
+		HostUsername: targetUsername,
+		Attackers:    travel.Thieves,
+		AttackerKind: "thieves",
+		Caught:       caughtThieves,
+	})
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func completeStealReturn(ctx updateContext, world *internal.WorldService, travel *models.Travel, travelIndex int) (error) {
 	ctx.IncrCoins(int32(travel.Coins))
+	ctx.IncrPizzas(int32(travel.Pizzas))
 	ctx.IncrThieves(travel.Thieves)
 
 	log.Info().
 		Str("userId", ctx.userId).
-		Int64("loot", travel.Coins).
+		Int64("coins", travel.Coins).
+		Int64("pizzas", travel.Pizzas).
 		Msg("Steal return completed")
 
 	return nil
 }
 
 func completeTravels(ctx updateContext, r internal.RedisClient, world *internal.WorldService) (error) {
-	completedTravels := internal.GetCompletedTravels(ctx.gs)
+	completedTravels, pendingTravels := internal.SplitCompletedTravels(
+		ctx.gs.TravelQueue, time.Now().UnixNano())
 	if len(completedTravels) == 0 {
 		return nil
 	}
 
 	// Update patch
-	ctx.patch.gsPatch.TravelQueue = ctx.gs.TravelQueue[len(completedTravels):]
+	ctx.patch.gsPatch.TravelQueue = pendingTravels
 	ctx.patch.gsPatch.TravelQueuePatched = true
 
 	// Complete travels
@@ -227,6 +363,30 @@ func completeTravels(ctx updateContext, r internal.RedisClient, world *internal.
 					return err
 				}
 			}
+			if travel.Scouts > 0 {
+				err := completeScoutReturn(ctx, travel)
+				if err != nil {
+					return err
+				}
+			}
+			if travel.Saboteurs > 0 {
+				err := completeSabotageReturn(ctx, travel)
+				if err != nil {
+					return err
+				}
+			}
+			if travel.Couriers > 0 {
+				err := completeTransferReturn(ctx, travel)
+				if err != nil {
+					return err
+				}
+			}
+			if travel.Guards > 0 {
+				err := completeReinforceReturn(ctx, travel)
+				if err != nil {
+					return err
+				}
+			}
 		} else {
 			if travel.Thieves > 0 {
 				err := completeSteal(ctx, r, world, travel, travelIndex)
@@ -234,8 +394,35 @@ func completeTravels(ctx updateContext, r internal.RedisClient, world *internal.
 					return err
 				}
 			}
+			if travel.Scouts > 0 {
+				err := completeScout(ctx, r, world, travel)
+				if err != nil {
+					return err
+				}
+			}
+			if travel.Saboteurs > 0 {
+				err := completeSabotage(ctx, r, world, travel)
+				if err != nil {
+					return err
+				}
+			}
+			if travel.Couriers > 0 {
+				err := completeTransfer(ctx, r, world, travel)
+				if err != nil {
+					return err
+				}
+			}
+			if travel.Guards > 0 {
+				err := completeReinforce(ctx, r, world, travel)
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 
+	// Return travels are appended when travels complete
+	internal.SortTravelQueue(ctx.patch.gsPatch.TravelQueue)
+
 	return nil
 }
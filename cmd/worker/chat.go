@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/rs/xid"
+)
+
+func (h *handler) handlePostChatMessage(ctx context.Context, senderId string, m *models.ClientMessage_PostChatMessage) error {
+	delivery, err := h.chat.Post(ctx, senderId, m.Channel, m.Text, m.Username)
+	if err != nil {
+		return fmt.Errorf("failed to handle post chat message: %w", err)
+	}
+
+	msg := &models.ServerMessage{
+		Id: xid.New().String(),
+		Payload: &models.ServerMessage_ChatMessage{
+			ChatMessage: delivery.Message,
+		},
+	}
+
+	if delivery.Broadcast {
+		b, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return h.rdb.RPush(ctx, "wsout", &internal.OutgoingMessage{
+			Body:       string(b),
+			Broadcast:  true,
+			ExcludeIds: delivery.ExcludeIds,
+		}).Err()
+	}
+
+	for _, userId := range delivery.RecipientIds {
+		if err = h.send(ctx, userId, msg); err != nil {
+			return fmt.Errorf("failed to send chat message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *handler) handleIgnoreUser(ctx context.Context, senderId string, m *models.ClientMessage_IgnoreUser) error {
+	if err := h.chat.Ignore(ctx, senderId, m.Username); err != nil {
+		return fmt.Errorf("failed to handle ignore user: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleUnignoreUser(ctx context.Context, senderId string, m *models.ClientMessage_UnignoreUser) error {
+	if err := h.chat.Unignore(ctx, senderId, m.Username); err != nil {
+		return fmt.Errorf("failed to handle unignore user: %w", err)
+	}
+	return nil
+}
@@ -45,10 +45,11 @@ func (h *handler) handleConstructBuilding(ctx context.Context, senderId string,
 			}
 		}
 
-		// The first building of all types except markting hq and research institute
-		// are free and built 100 times faster
+		// The first building of all types except markting hq, research institute
+		// and builder's guild are free and built 100 times faster
 		if m.Building != models.Building_MARKETINGHQ &&
 			m.Building != models.Building_RESEARCH_INSTITUTE &&
+			m.Building != models.Building_BUILDERS_GUILD &&
 			buildingCount[int32(m.Building)]+buildingConstrCount[int32(m.Building)] == 0 {
 			cost = 0
 			constructionTime = int32(float64(constructionTime)/100.0) + 1
@@ -59,13 +60,12 @@ func (h *handler) handleConstructBuilding(ctx context.Context, senderId string,
 		}
 
 		// Calculate when this construction will be completed.
-		// If there's already already something being constructed, this building will
-		// be started at the end of previous one. If there's nothing in queue, it can
-		// be started immediately (time.Now()).
-		timeOffset := time.Now().UnixNano()
-		if n := len(gs.ConstructionQueue); n > 0 {
-			timeOffset = gs.ConstructionQueue[n-1].CompleteAt
-		}
+		// The construction is started in the earliest free construction slot.
+		// If there's a free slot, it can be started immediately (time.Now()).
+		timeOffset := internal.GetConstructionStartTime(
+			gs.ConstructionQueue,
+			internal.CountConstructionSlots(&gs),
+			time.Now().UnixNano())
 		completeAt := timeOffset + int64(constructionTime)*1e9
 
 		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
@@ -13,6 +13,9 @@ import (
 type handler struct {
 	rdb internal.RedisClient
 	world *internal.WorldService
+	market *internal.MarketService
+	tribes *internal.TribeService
+	chat *internal.ChatService
 }
 
 func (h *handler) Handle(ctx context.Context, senderId string, m *models.ClientMessage) {
@@ -37,6 +40,56 @@ func (h *handler) Handle(ctx context.Context, senderId string, m *models.ClientM
 		err = h.handleCancelRazeBuilding(ctx, senderId, x.CancelRazeBuilding)
 	case *models.ClientMessage_StartResearch_:
 		err = h.handleStartResearch(ctx, senderId, x.StartResearch)
+	case *models.ClientMessage_Scout_:
+		err = h.handleScout(ctx, senderId, x.Scout)
+	case *models.ClientMessage_Sabotage_:
+		err = h.handleSabotage(ctx, senderId, x.Sabotage)
+	case *models.ClientMessage_RepairBuilding_:
+		err = h.handleRepairBuilding(ctx, senderId, x.RepairBuilding)
+	case *models.ClientMessage_PlaceMarketOrder_:
+		err = h.handlePlaceMarketOrder(ctx, senderId, x.PlaceMarketOrder)
+	case *models.ClientMessage_CancelMarketOrder_:
+		err = h.handleCancelMarketOrder(ctx, senderId, x.CancelMarketOrder)
+	case *models.ClientMessage_CreateTribe_:
+		err = h.handleCreateTribe(ctx, senderId, x.CreateTribe)
+	case *models.ClientMessage_InviteToTribe_:
+		err = h.handleInviteToTribe(ctx, senderId, x.InviteToTribe)
+	case *models.ClientMessage_JoinTribe_:
+		err = h.handleJoinTribe(ctx, senderId, x.JoinTribe)
+	case *models.ClientMessage_DeclineTribeInvitation_:
+		err = h.handleDeclineTribeInvitation(ctx, senderId, x.DeclineTribeInvitation)
+	case *models.ClientMessage_LeaveTribe_:
+		err = h.handleLeaveTribe(ctx, senderId, x.LeaveTribe)
+	case *models.ClientMessage_KickFromTribe_:
+		err = h.handleKickFromTribe(ctx, senderId, x.KickFromTribe)
+	case *models.ClientMessage_SetTribeRole_:
+		err = h.handleSetTribeRole(ctx, senderId, x.SetTribeRole)
+	case *models.ClientMessage_UpdateTribeProfile_:
+		err = h.handleUpdateTribeProfile(ctx, senderId, x.UpdateTribeProfile)
+	case *models.ClientMessage_PostChatMessage_:
+		err = h.handlePostChatMessage(ctx, senderId, x.PostChatMessage)
+	case *models.ClientMessage_IgnoreUser_:
+		err = h.handleIgnoreUser(ctx, senderId, x.IgnoreUser)
+	case *models.ClientMessage_UnignoreUser_:
+		err = h.handleUnignoreUser(ctx, senderId, x.UnignoreUser)
+	case *models.ClientMessage_SendLetter_:
+		err = h.handleSendLetter(ctx, senderId, x.SendLetter)
+	case *models.ClientMessage_ReplyLetter_:
+		err = h.handleReplyLetter(ctx, senderId, x.ReplyLetter)
+	case *models.ClientMessage_ReadLetter_:
+		err = h.handleReadLetter(ctx, senderId, x.ReadLetter)
+	case *models.ClientMessage_ArchiveLetter_:
+		err = h.handleArchiveLetter(ctx, senderId, x.ArchiveLetter)
+	case *models.ClientMessage_DeleteLetter_:
+		err = h.handleDeleteLetter(ctx, senderId, x.DeleteLetter)
+	case *models.ClientMessage_TransferCoins_:
+		err = h.handleTransferCoins(ctx, senderId, x.TransferCoins)
+	case *models.ClientMessage_SendReinforcements_:
+		err = h.handleSendReinforcements(ctx, senderId, x.SendReinforcements)
+	case *models.ClientMessage_RecallReinforcements_:
+		err = h.handleRecallReinforcements(ctx, senderId, x.RecallReinforcements)
+	case *models.ClientMessage_RecallTravel_:
+		err = h.handleRecallTravel(ctx, senderId, x.RecallTravel)
 	default:
 		log.Info().Str("senderId", senderId).Msg("Received message")
 	}
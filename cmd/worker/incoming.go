@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
+)
+
+// Rolls whether the guards of the target town spot the thieves on their
+// way, and warns the target if they do. The more guards, the more likely
+// they are to spot the thieves.
+func (h *handler) detectIncomingTravel(ctx context.Context, targetId string, gsSender *models.GameState, travel *models.Travel) error {
+	s, err := internal.RedisJsonGet(h.rdb, ctx, fmt.Sprintf("user:%s:gamestate", targetId), ".").Result()
+	if err != nil {
+		return fmt.Errorf("failed to get game state of target: %w", err)
+	}
+	gsTarget := &models.GameState{}
+	if err = protojson.Unmarshal([]byte(s), gsTarget); err != nil {
+		return fmt.Errorf("failed to get game state of target: %w", err)
+	}
+
+	chance := internal.GetDetectionChance(internal.CountDefendingGuards(gsTarget), travel.Thieves)
+	worldSeed, err := internal.GetWorldSeed(ctx, h.rdb)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(internal.GetTravelSeed(worldSeed, "detect", travel)))
+	if rnd.Float64() >= chance {
+		return nil
+	}
+
+	incoming := &models.IncomingTravel{
+		Id:        travel.Id,
+		ArrivalAt: travel.ArrivalAt,
+		FromX:     gsSender.TownX,
+		FromY:     gsSender.TownY,
+		Thieves:   travel.Thieves,
+	}
+	if err = internal.AddIncomingTravel(ctx, h.rdb, targetId, incoming); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("userId", targetId).
+		Str("travelId", travel.Id).
+		Msg("Incoming thieves spotted")
+
+	err = h.send(ctx, targetId, &models.ServerMessage{
+		Id: xid.New().String(),
+		Payload: &models.ServerMessage_IncomingTravel{
+			IncomingTravel: incoming,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send incoming travel: %w", err)
+	}
+
+	h.sendFullStateUpdate(ctx, targetId)
+
+	return nil
+}
+
+// Removes a travel from the incoming travels of the town it was heading to
+func (h *handler) removeIncomingTravel(ctx context.Context, travel *models.Travel) error {
+	worldEntry, err := h.world.GetEntryXY(ctx, int(travel.DestinationX), int(travel.DestinationY))
+	if err != nil {
+		return err
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return nil
+	}
+
+	removed, err := internal.RemoveIncomingTravel(ctx, h.rdb, town.UserId, travel.Id)
+	if err != nil {
+		return err
+	}
+	if removed {
+		h.sendFullStateUpdate(ctx, town.UserId)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handleSendLetter(ctx context.Context, senderId string, m *models.ClientMessage_SendLetter) error {
+	letter, err := internal.SendLetter(ctx, h.rdb, senderId, m.Username, m.Subject, m.Content, "")
+	if err != nil {
+		return fmt.Errorf("failed to handle send letter: %w", err)
+	}
+
+	if letter.RecipientId != "" {
+		h.sendMailbox(ctx, letter.RecipientId)
+	}
+
+	return nil
+}
+
+func (h *handler) handleReplyLetter(ctx context.Context, senderId string, m *models.ClientMessage_ReplyLetter) error {
+	letter, err := internal.ReplyLetter(ctx, h.rdb, senderId, m.Id, m.Content)
+	if err != nil {
+		return fmt.Errorf("failed to handle reply letter: %w", err)
+	}
+
+	if letter.RecipientId != "" {
+		h.sendMailbox(ctx, letter.RecipientId)
+	}
+
+	return nil
+}
+
+func (h *handler) handleReadLetter(ctx context.Context, senderId string, m *models.ClientMessage_ReadLetter) error {
+	if err := internal.MarkLetterAsRead(ctx, h.rdb, senderId, m.Id); err != nil {
+		return fmt.Errorf("failed to handle read letter: %w", err)
+	}
+
+	h.sendMailbox(ctx, senderId)
+
+	return nil
+}
+
+func (h *handler) handleArchiveLetter(ctx context.Context, senderId string, m *models.ClientMessage_ArchiveLetter) error {
+	if err := internal.ArchiveLetter(ctx, h.rdb, senderId, m.Id); err != nil {
+		return fmt.Errorf("failed to handle archive letter: %w", err)
+	}
+
+	h.sendMailbox(ctx, senderId)
+
+	return nil
+}
+
+func (h *handler) handleDeleteLetter(ctx context.Context, senderId string, m *models.ClientMessage_DeleteLetter) error {
+	if err := internal.DeleteLetter(ctx, h.rdb, senderId, m.Id); err != nil {
+		return fmt.Errorf("failed to handle delete letter: %w", err)
+	}
+
+	h.sendMailbox(ctx, senderId)
+
+	return nil
+}
+
+func (h *handler) sendMailbox(ctx context.Context, userId string) {
+	letters, err := internal.GetLetters(ctx, h.rdb, userId)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send mailbox")
+		return
+	}
+	unread, err := internal.CountUnreadLetters(ctx, h.rdb, userId)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send mailbox")
+		return
+	}
+
+	err = h.send(ctx, userId, &models.ServerMessage{
+		Id: xid.New().String(),
+		Payload: &models.ServerMessage_Mailbox_{
+			Mailbox: &models.ServerMessage_Mailbox{
+				Letters:     letters,
+				UnreadCount: int32(unread),
+			},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send mailbox")
+	}
+}
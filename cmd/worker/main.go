@@ -39,8 +39,17 @@ func main() {
 	rc := internal.NewRedisClient(rdb)
 
 	world := internal.NewWorldService(rc)
-
-	h := &handler{rdb: rc, world: world}
+	market := internal.NewMarketService(rc)
+	tribes := internal.NewTribeService(rc)
+	chat := internal.NewChatService(rc, world)
+
+	h := &handler{
+		rdb:    rc,
+		world:  world,
+		market: market,
+		tribes: tribes,
+		chat:   chat,
+	}
 
 	ctx := context.Background()
 
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handlePlaceMarketOrder(ctx context.Context, senderId string, m *models.ClientMessage_PlaceMarketOrder) error {
+	affected, err := h.market.PlaceOrder(ctx, senderId, m.Side, m.Price, m.Amount)
+
+	// Trades might have been settled even if a later one failed, so the
+	// affected users are updated in any case. Every trade gives a report to
+	// both the buyer and the seller.
+	sent := map[string]bool{}
+	for _, userId := range affected {
+		if sent[userId] {
+			continue
+		}
+		sent[userId] = true
+		h.sendFullStateUpdate(ctx, userId)
+		if len(affected) > 1 {
+			h.sendReports(ctx, userId)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to handle place market order: %w", err)
+	}
+
+	log.Info().
+		Str("userId", senderId).
+		Str("side", m.Side.String()).
+		Int64("price", m.Price).
+		Int32("amount", m.Amount).
+		Int("trades", len(affected)-1).
+		Msg("Market order placed")
+
+	return nil
+}
+
+func (h *handler) handleCancelMarketOrder(ctx context.Context, senderId string, m *models.ClientMessage_CancelMarketOrder) error {
+	if err := h.market.CancelOrder(ctx, senderId, m.Id); err != nil {
+		return fmt.Errorf("failed to handle cancel market order: %w", err)
+	}
+
+	h.sendFullStateUpdate(ctx, senderId)
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handleSendReinforcements(ctx context.Context, senderId string, m *models.ClientMessage_SendReinforcements) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", senderId)
+
+	var gs models.GameState
+
+	if m.Amount <= 0 {
+		return errors.New("Amount must be greater than 0")
+	}
+
+	// Validate target town
+	worldEntry, err := h.world.GetEntryXY(ctx, int(m.X), int(m.Y))
+	if err != nil {
+		return err
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", m.X, m.Y)
+	}
+	if town.UserId == senderId {
+		return errors.New("can't reinforce own town")
+	}
+
+	// Guards can only be stationed in the towns of tribe members
+	allies, err := h.tribes.AreAllies(ctx, senderId, town.UserId)
+	if err != nil {
+		return err
+	}
+	if !allies {
+		return errors.New("can only reinforce towns of tribe members")
+	}
+
+	txf := func() error {
+		// Get game state of sender
+		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKey, ".").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), &gs); err != nil {
+			return err
+		}
+
+		if gs.Population == nil || gs.Population.Guards < m.Amount {
+			return errors.New("not enough guards")
+		}
+
+		arrivalAt, err := h.world.CalculateArrivalTime(ctx,
+			gs.TownX, gs.TownY,
+			m.X, m.Y,
+			internal.GuardSpeed)
+		if err != nil {
+			return err
+		}
+
+		travel := models.Travel{
+			Id:           xid.New().String(),
+			DepartureAt:  time.Now().UnixNano(),
+			ArrivalAt:    arrivalAt,
+			DestinationX: m.X,
+			DestinationY: m.Y,
+			Returning:    false,
+			Guards:       m.Amount,
+		}
+
+		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			_, err := internal.RedisJsonNumIncrBy(
+				pipe, ctx, gsKey,
+				".population.guards",
+				int64(-travel.Guards)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to decrease guards of sender: %w", err)
+			}
+
+			if err = internal.InsertTravel(ctx, pipe, gsKey,
+				gs.TravelQueue, &travel); err != nil {
+				return err
+			}
+
+			log.Info().
+				Int32("guards", travel.Guards).
+				Time("arrivalAt", time.Unix(0, travel.ArrivalAt)).
+				Msg("Reinforcements dispatched")
+
+			return nil
+		})
+
+		return err
+	}
+
+	mutex := h.rdb.NewMutex("lock:" + gsKey)
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	err2 := txf()
+	if ok, err := mutex.Unlock(); !ok || err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if err2 != nil {
+		return fmt.Errorf("failed to handle send reinforcements: %w", err2)
+	}
+
+	h.fetchAndUpdateTimestamp(ctx, senderId)
+	h.sendFullStateUpdate(ctx, senderId)
+
+	return nil
+}
+
+// Recalls all guards that the sender has stationed in the town at x, y. The
+// guards leave the town right away and travel back home.
+func (h *handler) handleRecallReinforcements(ctx context.Context, senderId string, m *models.ClientMessage_RecallReinforcements) error {
+	worldEntry, err := h.world.GetEntryXY(ctx, int(m.X), int(m.Y))
+	if err != nil {
+		return err
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", m.X, m.Y)
+	}
+
+	recalled, err := internal.RecallReinforcements(ctx, h.rdb, h.world, senderId, town.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to handle recall reinforcements: %w", err)
+	}
+	if recalled == 0 {
+		return errors.New("no guards stationed in town")
+	}
+
+	log.Info().
+		Int32("guards", recalled).
+		Str("hostId", town.UserId).
+		Msg("Reinforcements recalled")
+
+	h.sendFullStateUpdate(ctx, senderId)
+	h.sendFullStateUpdate(ctx, town.UserId)
+
+	return nil
+}
@@ -5,8 +5,30 @@ import (
 
 	"github.com/fnatte/pizza-tribes/internal"
 	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
 )
 
 func (h *handler) handleReadReport(ctx context.Context, userId string, m *models.ClientMessage_ReadReport) error {
 	return internal.MarkReportAsRead(ctx, h.rdb, userId, m.Id)
 }
+
+func (h *handler) sendReports(ctx context.Context, userId string) {
+	reports, err := internal.GetReports(ctx, h.rdb, userId)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send reports")
+		return
+	}
+
+	err = h.send(ctx, userId, &models.ServerMessage{
+		Id: xid.New().String(),
+		Payload: &models.ServerMessage_Reports_{
+			Reports: &models.ServerMessage_Reports{
+				Reports: reports,
+			},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send reports")
+	}
+}
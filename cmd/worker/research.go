@@ -13,8 +13,16 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Finds the node of the discovery d if all its prerequisites has been
+// discovered. The prerequisites of a node is its parent nodes and any
+// additional requirements, which can be discoveries in other tracks.
 func findDiscoveredNode(gs *models.GameState, node *models.ResearchNode, d models.ResearchDiscovery) *models.ResearchNode {
 	if node.Discovery == d {
+		for _, req := range node.Requirements {
+			if !gs.HasDiscovery(req) {
+				return nil
+			}
+		}
 		return node
 	}
 
@@ -59,20 +67,24 @@ func (h *handler) handleStartResearch(ctx context.Context, senderId string, m *m
 		if gs.HasDiscovery(node.Discovery) {
 			return fmt.Errorf("This research has already been discovered")
 		}
+		for _, r := range gs.ResearchQueue {
+			if r.Discovery == node.Discovery {
+				return fmt.Errorf("This research is already being researched")
+			}
+		}
 
 		if gs.Resources.Coins < node.Cost {
 			return errors.New("Not enough coins")
 		}
 
 		// Calculate when this research will be completed.
-		// If there's already already something being researched, this research will
-		// be started at the end of previous one. If there's nothing in queue, it can
-		// be started immediately (time.Now()).
-		timeOffset := time.Now().UnixNano()
-		if n := len(gs.ResearchQueue); n > 0 {
-			timeOffset = gs.ResearchQueue[n-1].CompleteAt
-		}
-		completeAt := timeOffset + int64(node.ResearchTime)*1e9
+		// The research is started in the earliest free research slot. If
+		// there's a free slot, it can be started immediately (time.Now()).
+		// A better research institute will also make the research faster.
+		slots, speed := internal.CountResearchSlots(&gs)
+		timeOffset := internal.GetResearchStartTime(
+			gs.ResearchQueue, slots, time.Now().UnixNano())
+		completeAt := timeOffset + int64(float64(node.ResearchTime)/speed*1e9)
 
 		research := models.OngoingResearch{
 			CompleteAt: completeAt,
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handleSabotage(ctx context.Context, senderId string, m *models.ClientMessage_Sabotage) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", senderId)
+
+	var gs models.GameState
+
+	if m.Amount <= 0 {
+		return errors.New("Amount must be greater than 0")
+	}
+
+	// Validate target town
+	worldEntry, err := h.world.GetEntryXY(ctx, int(m.X), int(m.Y))
+	if err != nil {
+		return err
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", m.X, m.Y)
+	}
+	if town.UserId == senderId {
+		return errors.New("can't sabotage own town")
+	}
+
+	txf := func() error {
+		// Get game state of sabotaging user
+		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKey, ".").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), &gs); err != nil {
+			return err
+		}
+
+		if gs.Population == nil || gs.Population.Saboteurs < m.Amount {
+			return errors.New("no enough saboteurs")
+		}
+
+		arrivalAt, err := h.world.CalculateArrivalTime(ctx,
+			gs.TownX, gs.TownY,
+			m.X, m.Y,
+			internal.SaboteurSpeed)
+		if err != nil {
+			return err
+		}
+
+		travel := models.Travel{
+			Id:           xid.New().String(),
+			DepartureAt:  time.Now().UnixNano(),
+			ArrivalAt:    arrivalAt,
+			DestinationX: m.X,
+			DestinationY: m.Y,
+			Returning:    false,
+			Saboteurs:    m.Amount,
+		}
+
+		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// Decrease saboteurs in town population of sending town
+			_, err := internal.RedisJsonNumIncrBy(
+				pipe, ctx, gsKey,
+				".population.saboteurs",
+				int64(-travel.Saboteurs)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to decrease saboteurs of sender: %w", err)
+			}
+
+			if err = internal.InsertTravel(ctx, pipe, gsKey,
+				gs.TravelQueue, &travel); err != nil {
+				return err
+			}
+
+			log.Info().
+				Int32("saboteurs", travel.Saboteurs).
+				Time("arrivalAt", time.Unix(0, travel.ArrivalAt)).
+				Msg("Saboteurs dispatched")
+
+			return nil
+		})
+
+		return err
+	}
+
+	mutex := h.rdb.NewMutex("lock:" + gsKey)
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	err2 := txf()
+	if ok, err := mutex.Unlock(); !ok || err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if err2 != nil {
+		return fmt.Errorf("failed to handle sabotage: %w", err2)
+	}
+
+	h.fetchAndUpdateTimestamp(ctx, senderId)
+	h.sendFullStateUpdate(ctx, senderId)
+
+	return nil
+}
+
+// Returns the cost of repairing a building that has been disabled by
+// saboteurs. Repairing is cheaper than constructing the building again.
+func getRepairCost(lot *models.GameState_Lot) int32 {
+	buildingInfo := internal.FullGameData.Buildings[int32(lot.Building)]
+	return buildingInfo.LevelInfos[lot.Level].Cost / 4
+}
+
+func (h *handler) handleRepairBuilding(ctx context.Context, senderId string, m *models.ClientMessage_RepairBuilding) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", senderId)
+
+	var gs models.GameState
+
+	txf := func() error {
+		// Get current game state
+		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKey, ".").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), &gs); err != nil {
+			return err
+		}
+
+		lot := gs.Lots[m.LotId]
+		if lot == nil {
+			return errors.New("no building in lot")
+		}
+		if !internal.IsLotDisabled(lot, time.Now().UnixNano()) {
+			return errors.New("the building is not damaged")
+		}
+
+		cost := getRepairCost(lot)
+		if gs.Resources.Coins < cost {
+			return errors.New("Not enough coins")
+		}
+
+		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			_, err := internal.RedisJsonNumIncrBy(
+				pipe, ctx, gsKey,
+				".resources.coins",
+				int64(-cost)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to decrease coins: %w", err)
+			}
+
+			err = internal.RedisJsonSet(pipe, ctx, gsKey,
+				fmt.Sprintf(".lots[\"%s\"].disabledUntil", m.LotId),
+				int64(0)).Err()
+			if err != nil {
+				return fmt.Errorf("failed to repair building: %w", err)
+			}
+
+			return nil
+		})
+
+		return err
+	}
+
+	mutex := h.rdb.NewMutex("lock:" + gsKey)
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	err2 := txf()
+	if ok, err := mutex.Unlock(); !ok || err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if err2 != nil {
+		return fmt.Errorf("failed to handle repair: %w", err2)
+	}
+
+	h.fetchAndUpdateTimestamp(ctx, senderId)
+	h.sendFullStateUpdate(ctx, senderId)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handleScout(ctx context.Context, senderId string, m *models.ClientMessage_Scout) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", senderId)
+
+	var gs models.GameState
+
+	if m.Amount <= 0 {
+		return errors.New("Amount must be greater than 0")
+	}
+
+	// Validate target town
+	worldEntry, err := h.world.GetEntryXY(ctx, int(m.X), int(m.Y))
+	if err != nil {
+		return err
+	}
+	town := worldEntry.GetTown()
+	if town == nil {
+		return fmt.Errorf("no town at %d, %d", m.X, m.Y)
+	}
+	if town.UserId == senderId {
+		return errors.New("can't scout own town")
+	}
+
+	txf := func() error {
+		// Get game state of scouting user
+		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKey, ".").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), &gs); err != nil {
+			return err
+		}
+
+		if gs.Population == nil || gs.Population.Scouts < m.Amount {
+			return errors.New("no enough scouts")
+		}
+
+		arrivalAt, err := h.world.CalculateArrivalTime(ctx,
+			gs.TownX, gs.TownY,
+			m.X, m.Y,
+			internal.ScoutSpeed)
+		if err != nil {
+			return err
+		}
+
+		travel := models.Travel{
+			Id:           xid.New().String(),
+			DepartureAt:  time.Now().UnixNano(),
+			ArrivalAt:    arrivalAt,
+			DestinationX: m.X,
+			DestinationY: m.Y,
+			Returning:    false,
+			Scouts:       m.Amount,
+		}
+
+		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// Decrease scouts in town population of sending town
+			_, err := internal.RedisJsonNumIncrBy(
+				pipe, ctx, gsKey,
+				".population.scouts",
+				int64(-travel.Scouts)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to decrease scouts of sender: %w", err)
+			}
+
+			if err = internal.InsertTravel(ctx, pipe, gsKey,
+				gs.TravelQueue, &travel); err != nil {
+				return err
+			}
+
+			log.Info().
+				Int32("scouts", travel.Scouts).
+				Time("arrivalAt", time.Unix(0, travel.ArrivalAt)).
+				Msg("Scouts dispatched")
+
+			return nil
+		})
+
+		return err
+	}
+
+	mutex := h.rdb.NewMutex("lock:" + gsKey)
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	err2 := txf()
+	if ok, err := mutex.Unlock(); !ok || err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if err2 != nil {
+		return fmt.Errorf("failed to handle scout: %w", err2)
+	}
+
+	h.fetchAndUpdateTimestamp(ctx, senderId)
+	h.sendFullStateUpdate(ctx, senderId)
+
+	return nil
+}
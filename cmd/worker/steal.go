@@ -10,6 +10,7 @@ import (
 	"github.com/fnatte/pizza-tribes/internal/models"
 	"github.com/fnatte/pizza-tribes/internal/protojson"
 	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,6 +18,11 @@ func (h *handler) handleSteal(ctx context.Context, senderId string, m *models.Cl
 	gsKeyThief := fmt.Sprintf("user:%s:gamestate", senderId)
 
 	var gsThief models.GameState
+	var dispatched *models.Travel
+
+	if _, ok := models.Resource_name[int32(m.Resource)]; !ok {
+		return errors.New("invalid resource")
+	}
 
 	// Validate target town
 	worldEntry, err := h.world.GetEntryXY(ctx, int(m.X), int(m.Y))
@@ -31,6 +37,19 @@ func (h *handler) handleSteal(ctx context.Context, senderId string, m *models.Cl
 		return errors.New("can't steal from own town")
 	}
 
+	// Validate that the target can be robbed
+	var gsTarget models.GameState
+	s, err := internal.RedisJsonGet(h.rdb, ctx, fmt.Sprintf("user:%s:gamestate", town.UserId), ".").Result()
+	if err != nil {
+		return err
+	}
+	if err = protojson.Unmarshal([]byte(s), &gsTarget); err != nil {
+		return err
+	}
+	if err = internal.CheckCanSteal(ctx, h.rdb, senderId, town.UserId, &gsTarget); err != nil {
+		return err
+	}
+
 	txf := func() error {
 		// Get game state of thief
 		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKeyThief, ".").Result()
@@ -46,23 +65,23 @@ func (h *handler) handleSteal(ctx context.Context, senderId string, m *models.Cl
 			return errors.New("no enough thieves")
 		}
 
-		arrivalAt := internal.CalculateArrivalTime(
+		arrivalAt, err := h.world.CalculateArrivalTime(ctx,
 			gsThief.TownX, gsThief.TownY,
 			m.X, m.Y,
 			internal.ThiefSpeed)
+		if err != nil {
+			return err
+		}
 
 		travel := models.Travel{
+			Id:           xid.New().String(),
+			DepartureAt:  time.Now().UnixNano(),
 			ArrivalAt:    arrivalAt,
 			DestinationX: m.X,
 			DestinationY: m.Y,
 			Returning:    false,
 			Thieves:      m.Amount,
-			Coins:        0,
-		}
-
-		b, err := protojson.Marshal(&travel)
-		if err != nil {
-			return fmt.Errorf("failed to marshal travel: %w", err)
+			Resource:     m.Resource,
 		}
 
 		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
@@ -75,11 +94,20 @@ func (h *handler) handleSteal(ctx context.Context, senderId string, m *models.Cl
 				return fmt.Errorf("failed to decrease thieves of sender: %w", err)
 			}
 
-			if err = internal.RedisJsonArrAppend(pipe, ctx, gsKeyThief,
-				".travelQueue", b).Err(); err != nil {
+			if err = internal.InsertTravel(ctx, pipe, gsKeyThief,
+				gsThief.TravelQueue, &travel); err != nil {
 				return err
 			}
 
+			// Stealing from others ends the beginner protection
+			if gsThief.ProtectedUntil > 0 {
+				err = internal.RedisJsonSet(pipe, ctx, gsKeyThief,
+					".protectedUntil", 0).Err()
+				if err != nil {
+					return fmt.Errorf("failed to end beginner protection: %w", err)
+				}
+			}
+
 			log.Info().
 				Int32("thieves", travel.Thieves).
 				Time("arrivalAt", time.Unix(0, travel.ArrivalAt)).
@@ -87,8 +115,12 @@ func (h *handler) handleSteal(ctx context.Context, senderId string, m *models.Cl
 
 			return nil
 		})
+		if err != nil {
+			return err
+		}
 
-		return err
+		dispatched = &travel
+		return nil
 	}
 
 	mutex := h.rdb.NewMutex("lock:" + gsKeyThief)
@@ -105,5 +137,10 @@ func (h *handler) handleSteal(ctx context.Context, senderId string, m *models.Cl
 
 	h.sendFullStateUpdate(ctx, senderId)
 
+	err = h.detectIncomingTravel(ctx, town.UserId, &gsThief, dispatched)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to detect incoming travel")
+	}
+
 	return nil
 }
@@ -17,7 +17,6 @@ func (h *handler) handleTap(ctx context.Context, userId string, m *models.Client
 	gsKey := fmt.Sprintf("user:%s:gamestate", userId)
 	lotPath := fmt.Sprintf(".lots[\"%s\"]", m.LotId)
 	popPath := ".population"
-	tappedAtPath := fmt.Sprintf("%s.tapped_at", lotPath)
 	now := time.Now().UnixNano()
 
 	var lot models.GameState_Lot
@@ -56,6 +55,10 @@ func (h *handler) handleTap(ctx context.Context, userId string, m *models.Client
 			return fmt.Errorf("this building cannot be tapped")
 		}
 
+		if internal.IsLotDisabled(&lot, now) {
+			return fmt.Errorf("this building is damaged")
+		}
+
 		nextTapAt := lot.TappedAt + (60 * time.Minute).Nanoseconds()
 
 		if nextTapAt > now {
@@ -63,10 +66,14 @@ func (h *handler) handleTap(ctx context.Context, userId string, m *models.Client
 		}
 
 		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
-			// Update tapped_at to now
-			err = internal.RedisJsonSet(pipe, ctx, gsKey, tappedAtPath, int64(now)).Err()
+			// Update tapped at to now
+			b, err := internal.MarshalTappedLot(&lot, now)
+			if err != nil {
+				return fmt.Errorf("failed to marshal lot: %w", err)
+			}
+			err = internal.RedisJsonSet(pipe, ctx, gsKey, lotPath, b).Err()
 			if err != nil {
-				return fmt.Errorf("failed to set tapped_at: %w", err)
+				return fmt.Errorf("failed to set tapped at: %w", err)
 			}
 
 			// Increase the resource
@@ -43,13 +43,20 @@ func (h *handler) handleTrain(ctx context.Context, senderId string, m *models.Cl
 		}
 
 		eduInfo := internal.FullGameData.Educations[int32(m.Education)]
-		trainTime := int64(eduInfo.TrainTime)
 		cost := eduInfo.Cost * m.Amount
 
 		if gs.Resources.Coins < cost {
 			return errors.New("Not enough coins")
 		}
 
+		// The schools of the town determine how many mice can be trained
+		// at the same time, so the training is split into batches that
+		// are queued after the ongoing trainings.
+		capacity, speed := internal.CountTrainingCapacity(&gs)
+		batches := internal.ScheduleTrainings(
+			gs.TrainingQueue, capacity, speed,
+			m.Education, m.Amount, time.Now().UnixNano())
+
 		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
 			_, err := internal.RedisJsonNumIncrBy(
 				pipe,
@@ -71,26 +78,22 @@ func (h *handler) handleTrain(ctx context.Context, senderId string, m *models.Cl
 				return err
 			}
 
-			training := models.Training{
-				CompleteAt: time.Now().UnixNano() + trainTime*1e9,
-				Education:  m.Education,
-				Amount:     m.Amount,
-			}
-
-			b, err := protojson.Marshal(&training)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to marshal training")
-				return err
+			for _, training := range batches {
+				b, err := protojson.Marshal(training)
+				if err != nil {
+					log.Error().Err(err).Msg("Failed to marshal training")
+					return err
+				}
+
+				internal.RedisJsonArrAppend(
+					pipe,
+					ctx,
+					fmt.Sprintf("user:%s:gamestate", senderId),
+					".trainingQueue",
+					b,
+				)
 			}
 
-			internal.RedisJsonArrAppend(
-				pipe,
-				ctx,
-				fmt.Sprintf("user:%s:gamestate", senderId),
-				".trainingQueue",
-				b,
-			)
-
 			return nil
 		})
 		return err
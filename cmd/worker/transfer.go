@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handleTransferCoins(ctx context.Context, senderId string, m *models.ClientMessage_TransferCoins) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", senderId)
+
+	var gs models.GameState
+	var gsRecipient models.GameState
+
+	if m.Amount <= 0 {
+		return errors.New("Amount must be greater than 0")
+	}
+
+	// Find the town of the recipient
+	recipientId, err := h.rdb.Get(ctx, fmt.Sprintf("username:%s", strings.ToLower(m.Username))).Result()
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if recipientId == senderId {
+		return errors.New("can't transfer coins to self")
+	}
+	s, err := internal.RedisJsonGet(h.rdb, ctx, fmt.Sprintf("user:%s:gamestate", recipientId), ".").Result()
+	if err != nil {
+		return err
+	}
+	if err = protojson.Unmarshal([]byte(s), &gsRecipient); err != nil {
+		return err
+	}
+	entry, err := h.world.GetEntryXY(ctx, int(gsRecipient.TownX), int(gsRecipient.TownY))
+	if err != nil {
+		return err
+	}
+	if entry.GetTown() == nil || entry.GetTown().UserId != recipientId {
+		return errors.New("recipient has no town")
+	}
+
+	txf := func() error {
+		// Get game state of sending user
+		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKey, ".").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), &gs); err != nil {
+			return err
+		}
+
+		couriers := internal.CountCouriers(int64(m.Amount))
+		if gs.Resources == nil || gs.Resources.Coins < m.Amount {
+			return errors.New("not enough coins")
+		}
+		if gs.Population == nil || gs.Population.Uneducated < couriers {
+			return errors.New("not enough uneducated mice to carry the coins")
+		}
+
+		arrivalAt, err := h.world.CalculateArrivalTime(ctx,
+			gs.TownX, gs.TownY,
+			gsRecipient.TownX, gsRecipient.TownY,
+			internal.CourierSpeed)
+		if err != nil {
+			return err
+		}
+
+		// The limits are used last and given back if the couriers can not
+		// be dispatched, so that a failed transfer does not use them up
+		releaseLimits, err := internal.UseTransferLimits(ctx, h.rdb, senderId, recipientId, int64(m.Amount))
+		if err != nil {
+			return err
+		}
+
+		travel := models.Travel{
+			Id:           xid.New().String(),
+			DepartureAt:  time.Now().UnixNano(),
+			ArrivalAt:    arrivalAt,
+			DestinationX: gsRecipient.TownX,
+			DestinationY: gsRecipient.TownY,
+			Returning:    false,
+			Couriers:     couriers,
+			Coins:        int64(m.Amount),
+		}
+
+		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			_, err := internal.RedisJsonNumIncrBy(
+				pipe, ctx, gsKey,
+				".resources.coins",
+				-travel.Coins).Result()
+			if err != nil {
+				return fmt.Errorf("failed to decrease coins of sender: %w", err)
+			}
+
+			// The couriers are taken from the uneducated mice
+			_, err = internal.RedisJsonNumIncrBy(
+				pipe, ctx, gsKey,
+				".population.uneducated",
+				int64(-travel.Couriers)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to decrease uneducated of sender: %w", err)
+			}
+
+			if err = internal.InsertTravel(ctx, pipe, gsKey,
+				gs.TravelQueue, &travel); err != nil {
+				return err
+			}
+
+			log.Info().
+				Int64("coins", travel.Coins).
+				Int32("couriers", travel.Couriers).
+				Time("arrivalAt", time.Unix(0, travel.ArrivalAt)).
+				Msg("Couriers dispatched")
+
+			return nil
+		})
+		if err != nil {
+			if err2 := releaseLimits(); err2 != nil {
+				log.Error().Err(err2).Msg("Failed to release transfer limits")
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	mutex := h.rdb.NewMutex("lock:" + gsKey)
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	err2 := txf()
+	if ok, err := mutex.Unlock(); !ok || err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if err2 != nil {
+		return fmt.Errorf("failed to handle transfer coins: %w", err2)
+	}
+
+	h.fetchAndUpdateTimestamp(ctx, senderId)
+	h.sendFullStateUpdate(ctx, senderId)
+
+	return nil
+}
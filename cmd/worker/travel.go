@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fnatte/pizza-tribes/internal"
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// Turns an outbound travel around, so that it returns home from where it
+// currently is.
+func (h *handler) handleRecallTravel(ctx context.Context, senderId string, m *models.ClientMessage_RecallTravel) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", senderId)
+
+	var gs models.GameState
+	var recalled *models.Travel
+
+	if m.Id == "" {
+		return errors.New("missing travel id")
+	}
+
+	txf := func() error {
+		s, err := internal.RedisJsonGet(h.rdb, ctx, gsKey, ".").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err = protojson.Unmarshal([]byte(s), &gs); err != nil {
+			return err
+		}
+
+		idx := -1
+		for i, t := range gs.TravelQueue {
+			if t.Id == m.Id {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return errors.New("travel not found")
+		}
+
+		recalled, err = internal.RecallTravel(gs.TravelQueue[idx], time.Now().UnixNano())
+		if err != nil {
+			return err
+		}
+		gs.TravelQueue[idx] = recalled
+		internal.SortTravelQueue(gs.TravelQueue)
+
+		arr := []string{}
+		for _, t := range gs.TravelQueue {
+			b, err := protojson.Marshal(t)
+			if err != nil {
+				return fmt.Errorf("failed to marshal travel: %w", err)
+			}
+			arr = append(arr, string(b))
+		}
+		jsonarr := "[" + strings.Join(arr, ", ") + "]"
+
+		err = internal.RedisJsonSet(h.rdb, ctx, gsKey, ".travelQueue", jsonarr).Err()
+		if err != nil {
+			return fmt.Errorf("failed to write travel queue: %w", err)
+		}
+
+		log.Info().
+			Str("travelId", recalled.Id).
+			Time("arrivalAt", time.Unix(0, recalled.ArrivalAt)).
+			Msg("Travel recalled")
+
+		return nil
+	}
+
+	mutex := h.rdb.NewMutex("lock:" + gsKey)
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	err2 := txf()
+	if ok, err := mutex.Unlock(); !ok || err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	if err2 != nil {
+		return fmt.Errorf("failed to handle recall travel: %w", err2)
+	}
+
+	// The recalled travel might now be the next thing to happen
+	internal.SetNextUpdate(h.rdb, ctx, senderId, &gs)
+	h.sendFullStateUpdate(ctx, senderId)
+
+	// The target should no longer see the thieves coming
+	if recalled.Thieves > 0 {
+		if err := h.removeIncomingTravel(ctx, recalled); err != nil {
+			log.Error().Err(err).Msg("Failed to remove incoming travel")
+		}
+	}
+
+	return nil
+}
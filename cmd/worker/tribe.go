@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *handler) handleCreateTribe(ctx context.Context, senderId string, m *models.ClientMessage_CreateTribe) error {
+	tribe, err := h.tribes.Create(ctx, senderId, m.Name, m.Tag, m.Description)
+	if err != nil {
+		return fmt.Errorf("failed to handle create tribe: %w", err)
+	}
+
+	log.Info().
+		Str("userId", senderId).
+		Str("tribeId", tribe.Id).
+		Str("tag", tribe.Tag).
+		Msg("Tribe created")
+
+	return nil
+}
+
+func (h *handler) handleInviteToTribe(ctx context.Context, senderId string, m *models.ClientMessage_InviteToTribe) error {
+	if err := h.tribes.Invite(ctx, senderId, m.Username); err != nil {
+		return fmt.Errorf("failed to handle invite to tribe: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleJoinTribe(ctx context.Context, senderId string, m *models.ClientMessage_JoinTribe) error {
+	if err := h.tribes.Join(ctx, senderId, m.TribeId); err != nil {
+		return fmt.Errorf("failed to handle join tribe: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleDeclineTribeInvitation(ctx context.Context, senderId string, m *models.ClientMessage_DeclineTribeInvitation) error {
+	if err := h.tribes.DeclineInvitation(ctx, senderId, m.TribeId); err != nil {
+		return fmt.Errorf("failed to handle decline tribe invitation: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleLeaveTribe(ctx context.Context, senderId string, m *models.ClientMessage_LeaveTribe) error {
+	if err := h.tribes.Leave(ctx, senderId); err != nil {
+		return fmt.Errorf("failed to handle leave tribe: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleKickFromTribe(ctx context.Context, senderId string, m *models.ClientMessage_KickFromTribe) error {
+	if err := h.tribes.Kick(ctx, senderId, m.UserId); err != nil {
+		return fmt.Errorf("failed to handle kick from tribe: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleSetTribeRole(ctx context.Context, senderId string, m *models.ClientMessage_SetTribeRole) error {
+	if err := h.tribes.SetRole(ctx, senderId, m.UserId, m.Role); err != nil {
+		return fmt.Errorf("failed to handle set tribe role: %w", err)
+	}
+	return nil
+}
+
+func (h *handler) handleUpdateTribeProfile(ctx context.Context, senderId string, m *models.ClientMessage_UpdateTribeProfile) error {
+	if err := h.tribes.UpdateProfile(ctx, senderId, m.Description); err != nil {
+		return fmt.Errorf("failed to handle update tribe profile: %w", err)
+	}
+	return nil
+}
@@ -51,13 +51,12 @@ func (h *handler) handleUpgrade(ctx context.Context, senderId string, m *models.
 		}
 
 		// Calculate when this construction will be completed.
-		// If there's already already something being constructed, this building will
-		// be started at the end of previous one. If there's nothing in queue, it can
-		// be started immediately (time.Now()).
-		timeOffset := time.Now().UnixNano()
-		if n := len(gs.ConstructionQueue); n > 0 {
-			timeOffset = gs.ConstructionQueue[n-1].CompleteAt
-		}
+		// The construction is started in the earliest free construction slot.
+		// If there's a free slot, it can be started immediately (time.Now()).
+		timeOffset := internal.GetConstructionStartTime(
+			gs.ConstructionQueue,
+			internal.CountConstructionSlots(&gs),
+			time.Now().UnixNano())
 		completeAt := timeOffset + int64(constructionTime)*1e9
 
 		_, err = h.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+)
+
+// Max number of characters in a chat message
+const ChatMaxLength = 200
+
+// Number of messages kept in the history of each channel
+const ChatHistoryLength = 200
+const ChatHistoryPageSize = 50
+
+// Users can post at most ChatRateLimit messages per ChatRateLimitWindow
+const ChatRateLimit = 5
+const ChatRateLimitWindow = 10 * time.Second
+
+const ChatMaxIgnoredUsers = 100
+
+func getChatChannelKey(m *ChatMessage) string {
+	switch m.Channel {
+	case ChatMessage_ZONE:
+		return fmt.Sprintf("chat:zone:%d", m.Zone)
+	case ChatMessage_PRIVATE:
+		// Both users share the same channel
+		ids := []string{m.SenderId, m.RecipientId}
+		sort.Strings(ids)
+		return fmt.Sprintf("chat:private:%s:%s", ids[0], ids[1])
+	default:
+		return "chat:global"
+	}
+}
+
+func getUserIgnoredKey(userId string) string {
+	return fmt.Sprintf("user:%s:ignored", userId)
+}
+
+func getUserIgnoredByKey(userId string) string {
+	return fmt.Sprintf("user:%s:ignoredBy", userId)
+}
+
+// Describes who should receive a posted chat message
+type ChatDelivery struct {
+	Message *ChatMessage
+	// Users that should receive the message, unless it is a broadcast
+	RecipientIds []string
+	// Broadcasts are sent to everyone, except for the excluded users
+	Broadcast  bool
+	ExcludeIds []string
+}
+
+type ChatService struct {
+	r     RedisClient
+	world *WorldService
+}
+
+func NewChatService(r RedisClient, world *WorldService) *ChatService {
+	return &ChatService{r: r, world: world}
+}
+
+func (s *ChatService) getUserId(ctx context.Context, username string) (string, error) {
+	userId, err := s.r.Get(ctx, fmt.Sprintf("username:%s", strings.ToLower(username))).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user: %w", err)
+	}
+	return userId, nil
+}
+
+func (s *ChatService) getUsername(ctx context.Context, userId string) (string, error) {
+	username, err := s.r.HGet(ctx, fmt.Sprintf("user:%s", userId), "username").Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get username: %w", err)
+	}
+	return username, nil
+}
+
+// Returns the index of the world zone that the town of the user is in
+func (s *ChatService) getUserZone(ctx context.Context, userId string) (int32, error) {
+	str, err := RedisJsonGet(s.r, ctx, fmt.Sprintf("user:%s:gamestate", userId), ".").Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get game state: %w", err)
+	}
+	gs := &GameState{}
+	if err = protojson.Unmarshal([]byte(str), gs); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal game state: %w", err)
+	}
+	return int32(getZoneIdx(int(gs.TownX), int(gs.TownY))), nil
+}
+
+// Counts the message against the rate limit of the user and returns an
+// error if the user has posted too many messages recently.
+func (s *ChatService) checkRateLimit(ctx context.Context, userId string) error {
+	key := fmt.Sprintf("user:%s:chatRateLimit", userId)
+
+	// The counter is created together with its expiry, so that it can never
+	// be left without one
+	var incr *redis.IntCmd
+	_, err := s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key, 0, ChatRateLimitWindow)
+		incr = pipe.Incr(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check chat rate limit: %w", err)
+	}
+	if incr.Val() > ChatRateLimit {
+		return errors.New("too many chat messages")
+	}
+	return nil
+}
+
+func (s *ChatService) Post(ctx context.Context, senderId string, channel ChatMessage_Channel, text string, recipientUsername string) (*ChatDelivery, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("chat message is empty")
+	}
+	if utf8.RuneCountInString(text) > ChatMaxLength {
+		return nil, errors.New("chat message is too long")
+	}
+
+	senderUsername, err := s.getUsername(ctx, senderId)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &ChatMessage{
+		Id:             xid.New().String(),
+		Channel:        channel,
+		SenderId:       senderId,
+		SenderUsername: senderUsername,
+		Text:           text,
+		CreatedAt:      time.Now().UnixNano(),
+	}
+
+	switch channel {
+	case ChatMessage_ZONE:
+		if msg.Zone, err = s.getUserZone(ctx, senderId); err != nil {
+			return nil, err
+		}
+	case ChatMessage_PRIVATE:
+		if msg.RecipientId, err = s.getUserId(ctx, recipientUsername); err != nil {
+			return nil, err
+		}
+		if msg.RecipientId == senderId {
+			return nil, errors.New("can't send private message to self")
+		}
+		if msg.RecipientUsername, err = s.getUsername(ctx, msg.RecipientId); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.checkRateLimit(ctx, senderId); err != nil {
+		return nil, err
+	}
+
+	// Users that ignore the sender should not receive the message
+	ignoredBy, err := s.r.SMembers(ctx, getUserIgnoredByKey(senderId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignoring users: %w", err)
+	}
+	isIgnoredBy := map[string]bool{}
+	for _, id := range ignoredBy {
+		isIgnoredBy[id] = true
+	}
+
+	delivery := &ChatDelivery{Message: msg}
+	switch channel {
+	case ChatMessage_ZONE:
+		zone, err := s.world.GetZoneIdx(ctx, int(msg.Zone))
+		if err != nil {
+			return nil, err
+		}
+		if zone != nil {
+			for _, e := range zone.Entries {
+				if town := e.GetTown(); town != nil && !isIgnoredBy[town.UserId] {
+					delivery.RecipientIds = append(delivery.RecipientIds, town.UserId)
+				}
+			}
+		}
+	case ChatMessage_PRIVATE:
+		delivery.RecipientIds = []string{senderId}
+		if !isIgnoredBy[msg.RecipientId] {
+			delivery.RecipientIds = append(delivery.RecipientIds, msg.RecipientId)
+		}
+	default:
+		delivery.Broadcast = true
+		delivery.ExcludeIds = ignoredBy
+	}
+
+	// Save the message in the history of the channel
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat message: %w", err)
+	}
+	key := getChatChannelKey(msg)
+	_, err = s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, b)
+		pipe.LTrim(ctx, key, 0, ChatHistoryLength-1)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chat message: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// Returns a page of the history of a channel, newest messages first. Zone
+// history is always from the zone of the user, and private history is
+// between the user and the user with the username. Messages from ignored
+// users are left out.
+func (s *ChatService) GetHistory(ctx context.Context, userId string, channel ChatMessage_Channel, username string, skip int) (*ChatHistory, error) {
+	var err error
+	m := &ChatMessage{Channel: channel, SenderId: userId}
+
+	switch channel {
+	case ChatMessage_ZONE:
+		if m.Zone, err = s.getUserZone(ctx, userId); err != nil {
+			return nil, err
+		}
+	case ChatMessage_PRIVATE:
+		if m.RecipientId, err = s.getUserId(ctx, username); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := s.r.LRange(ctx, getChatChannelKey(m), int64(skip), int64(skip+ChatHistoryPageSize-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat history: %w", err)
+	}
+
+	ignored, err := s.r.SMembers(ctx, getUserIgnoredKey(userId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignored users: %w", err)
+	}
+	isIgnored := map[string]bool{}
+	for _, id := range ignored {
+		isIgnored[id] = true
+	}
+
+	history := &ChatHistory{
+		Skip:     int32(skip),
+		Messages: []*ChatMessage{},
+	}
+	for _, str := range res {
+		msg := &ChatMessage{}
+		if err = protojson.Unmarshal([]byte(str), msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chat message: %w", err)
+		}
+		if !isIgnored[msg.SenderId] {
+			history.Messages = append(history.Messages, msg)
+		}
+	}
+
+	return history, nil
+}
+
+// Returns the usernames of the users that the user ignores
+func (s *ChatService) GetIgnored(ctx context.Context, userId string) ([]string, error) {
+	ids, err := s.r.SMembers(ctx, getUserIgnoredKey(userId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignored users: %w", err)
+	}
+
+	usernames := make([]string, 0, len(ids))
+	for _, id := range ids {
+		username, err := s.getUsername(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	return usernames, nil
+}
+
+// Ignores all chat messages from the user with the username
+func (s *ChatService) Ignore(ctx context.Context, userId string, username string) error {
+	ignoredId, err := s.getUserId(ctx, username)
+	if err != nil {
+		return err
+	}
+	if ignoredId == userId {
+		return errors.New("can't ignore self")
+	}
+
+	count, err := s.r.SCard(ctx, getUserIgnoredKey(userId)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to count ignored users: %w", err)
+	}
+	if count >= ChatMaxIgnoredUsers {
+		return errors.New("too many ignored users")
+	}
+
+	_, err = s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, getUserIgnoredKey(userId), ignoredId)
+		pipe.SAdd(ctx, getUserIgnoredByKey(ignoredId), userId)
+		return nil
+	})
+	return err
+}
+
+func (s *ChatService) Unignore(ctx context.Context, userId string, username string) error {
+	ignoredId, err := s.getUserId(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SRem(ctx, getUserIgnoredKey(userId), ignoredId)
+		pipe.SRem(ctx, getUserIgnoredByKey(ignoredId), userId)
+		return nil
+	})
+	return err
+}
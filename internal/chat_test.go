@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetChatChannelKey(t *testing.T) {
+	tests := map[string]struct {
+		message *ChatMessage
+		want    string
+	}{
+		"global": {
+			message: &ChatMessage{Channel: ChatMessage_GLOBAL, Zone: 3, SenderId: "a"},
+			want:    "chat:global",
+		},
+		"zone": {
+			message: &ChatMessage{Channel: ChatMessage_ZONE, Zone: 3, SenderId: "a"},
+			want:    "chat:zone:3",
+		},
+		"private": {
+			message: &ChatMessage{Channel: ChatMessage_PRIVATE, SenderId: "a", RecipientId: "b"},
+			want:    "chat:private:a:b",
+		},
+		"private reply": {
+			message: &ChatMessage{Channel: ChatMessage_PRIVATE, SenderId: "b", RecipientId: "a"},
+			want:    "chat:private:a:b",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := getChatChannelKey(test.message)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("getChatChannelKey(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package combat resolves engagements between units sent to another town,
+// such as thieves, and the guards defending it. The resolver is pure and
+// deterministic given a seed, so that outcomes can be tested and
+// previewed.
+package combat
+
+import (
+	"math"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Units gain a level for every ExperiencePerLevel * level experience, so
+// that each level takes longer to reach than the one before it.
+const ExperiencePerLevel = 50
+const MaxLevel = 10
+
+// Every level makes a unit this much stronger
+const LevelBonus = 0.05
+
+// Experience gained by every attacker that gets past the guards, and by
+// the guards for every attacker they catch
+const ExperiencePerSuccess = 1
+
+// The highest chance that an attacker that gets past the guards injures
+// one of them. The stronger the guards, the lower the chance.
+const InjuryChance = 0.25
+
+// Returns the level of units with the experience
+func GetLevel(experience int32) int32 {
+	if experience <= 0 {
+		return 0
+	}
+	// Solves experience = ExperiencePerLevel * level * (level + 1) / 2
+	level := int32((math.Sqrt(1+8*float64(experience)/ExperiencePerLevel) - 1) / 2)
+	if level > MaxLevel {
+		return MaxLevel
+	}
+	return level
+}
+
+// Returns the combined strength of units at the level
+func GetStrength(units int32, level int32) float64 {
+	return float64(units) * (1 + LevelBonus*float64(level))
+}
+
+type Engagement struct {
+	Attackers     int32
+	AttackerLevel int32
+	Guards        int32
+	GuardLevel    int32
+	// How hard the attackers are to catch. Guards count as 1/Stealth of
+	// their strength against the attackers.
+	Stealth float64
+	Seed    uint64
+}
+
+type Outcome struct {
+	SuccessfulAttackers int32
+	CaughtAttackers     int32
+	InjuredGuards       int32
+	AttackerExperience  int32
+	GuardExperience     int32
+}
+
+// Returns the chance for each attacker to get past the guards
+func GetSuccessChance(e Engagement) float64 {
+	attack := GetStrength(e.Attackers, e.AttackerLevel)
+	if attack <= 0 {
+		return 0
+	}
+	stealth := e.Stealth
+	if stealth <= 0 {
+		stealth = 1
+	}
+	defence := GetStrength(e.Guards, e.GuardLevel) / stealth
+	return attack / (attack + defence)
+}
+
+// Resolves the engagement. The same engagement always has the same outcome.
+func Resolve(e Engagement) Outcome {
+	if e.Attackers <= 0 {
+		return Outcome{}
+	}
+
+	rnd := rand.New(rand.NewSource(e.Seed))
+	p := GetSuccessChance(e)
+
+	successful := int32(distuv.Binomial{
+		N:   float64(e.Attackers),
+		P:   p,
+		Src: rnd,
+	}.Rand())
+	caught := e.Attackers - successful
+
+	// Attackers that get past the guards might injure some of them on the
+	// way
+	var injured int32 = 0
+	if successful > 0 && e.Guards > 0 {
+		injured = int32(distuv.Binomial{
+			N:   float64(minInt32(successful, e.Guards)),
+			P:   InjuryChance * p,
+			Src: rnd,
+		}.Rand())
+	}
+
+	return Outcome{
+		SuccessfulAttackers: successful,
+		CaughtAttackers:     caught,
+		InjuredGuards:       injured,
+		AttackerExperience:  successful * ExperiencePerSuccess,
+		GuardExperience:     caught * ExperiencePerSuccess,
+	}
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,129 @@
+package combat
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetLevel(t *testing.T) {
+	tests := map[string]struct {
+		experience int32
+		want       int32
+	}{
+		"no experience":  {experience: 0, want: 0},
+		"almost level 1": {experience: 49, want: 0},
+		"level 1":        {experience: 50, want: 1},
+		"level 2":        {experience: 150, want: 2},
+		"level 3":        {experience: 300, want: 3},
+		"max level":      {experience: 1_000_000, want: MaxLevel},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetLevel(test.experience)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetLevel(%d) mismatch (-want +got):\n%s", test.experience, diff)
+			}
+		})
+	}
+}
+
+func TestGetSuccessChance(t *testing.T) {
+	tests := map[string]struct {
+		engagement Engagement
+		want       float64
+	}{
+		"no guards": {
+			engagement: Engagement{Attackers: 10, Stealth: 2},
+			want:       1,
+		},
+		"no attackers": {
+			engagement: Engagement{Guards: 10, Stealth: 2},
+			want:       0,
+		},
+		"even numbers": {
+			engagement: Engagement{Attackers: 10, Guards: 20, Stealth: 2},
+			want:       0.5,
+		},
+		"experienced guards": {
+			engagement: Engagement{Attackers: 10, Guards: 10, GuardLevel: 10, Stealth: 1},
+			want:       0.4,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetSuccessChance(test.engagement)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetSuccessChance() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := map[string]struct {
+		engagement Engagement
+		check      func(t *testing.T, o Outcome)
+	}{
+		"no attackers": {
+			engagement: Engagement{Guards: 10, Stealth: 2, Seed: 1},
+			check: func(t *testing.T, o Outcome) {
+				if diff := cmp.Diff(Outcome{}, o); diff != "" {
+					t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+				}
+			},
+		},
+		"no guards": {
+			engagement: Engagement{Attackers: 10, Stealth: 2, Seed: 1},
+			check: func(t *testing.T, o Outcome) {
+				want := Outcome{
+					SuccessfulAttackers: 10,
+					AttackerExperience:  10 * ExperiencePerSuccess,
+				}
+				if diff := cmp.Diff(want, o); diff != "" {
+					t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+				}
+			},
+		},
+		"everyone accounted for": {
+			engagement: Engagement{Attackers: 50, Guards: 40, Stealth: 2, Seed: 42},
+			check: func(t *testing.T, o Outcome) {
+				if o.SuccessfulAttackers+o.CaughtAttackers != 50 {
+					t.Errorf("Resolve() lost attackers: %+v", o)
+				}
+				if o.InjuredGuards > o.SuccessfulAttackers || o.InjuredGuards > 40 {
+					t.Errorf("Resolve() injured too many guards: %+v", o)
+				}
+				if o.GuardExperience != o.CaughtAttackers*ExperiencePerSuccess {
+					t.Errorf("Resolve() gave wrong guard experience: %+v", o)
+				}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.check(t, Resolve(test.engagement))
+		})
+	}
+}
+
+func TestResolveIsDeterministic(t *testing.T) {
+	e := Engagement{
+		Attackers:     100,
+		AttackerLevel: 2,
+		Guards:        80,
+		GuardLevel:    3,
+		Stealth:       2,
+		Seed:          1234,
+	}
+
+	want := Resolve(e)
+	for i := 0; i < 10; i++ {
+		if diff := cmp.Diff(want, Resolve(e)); diff != "" {
+			t.Fatalf("Resolve() is not deterministic (-want +got):\n%s", diff)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+)
+
+// Every town can always construct one building at a time. Additional
+// construction slots are unlocked by a Builder's Guild.
+const BaseConstructionSlots = 1
+
+// Returns the number of constructions that can run at the same time in the
+// town. Builder's guilds do not stack, only the best one counts.
+func CountConstructionSlots(gs *GameState) int32 {
+	var extra int32 = 0
+	now := time.Now().UnixNano()
+	for _, lot := range gs.Lots {
+		info := FullGameData.Buildings[int32(lot.Building)]
+		if info == nil || int(lot.Level) >= len(info.LevelInfos) || IsLotDisabled(lot, now) {
+			continue
+		}
+		if b := info.LevelInfos[lot.Level].Builder; b != nil && b.ConstructionSlots > extra {
+			extra = b.ConstructionSlots
+		}
+	}
+
+	return BaseConstructionSlots + extra
+}
+
+// Returns the time (unix nano) when a new construction can be started
+// in the earliest free construction slot.
+func GetConstructionStartTime(queue []*Construction, slots int32, now int64) int64 {
+	completeAts := make([]int64, len(queue))
+	for i, c := range queue {
+		completeAts[i] = c.CompleteAt
+	}
+
+	return getSlotStartTime(completeAts, slots, now)
+}
+
+// Splits the construction queue into the constructions that have been
+// completed and the ones that are still ongoing. Since constructions run
+// in parallel slots, the queue is not assumed to be sorted.
+func SplitCompletedConstructions(queue []*Construction, now int64) (completed, ongoing []*Construction) {
+	ongoing = []*Construction{}
+	for _, c := range queue {
+		if c.CompleteAt > now {
+			ongoing = append(ongoing, c)
+		} else {
+			completed = append(completed, c)
+		}
+	}
+
+	return completed, ongoing
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetConstructionStartTime(t *testing.T) {
+	type input struct {
+		completeAts []int64
+		slots       int32
+		now         int64
+	}
+
+	tests := map[string]struct {
+		input input
+		want  int64
+	}{
+		"empty queue": {
+			input: input{completeAts: []int64{}, slots: 1, now: 10},
+			want:  10,
+		},
+		"single slot": {
+			input: input{completeAts: []int64{20, 50}, slots: 1, now: 10},
+			want:  50,
+		},
+		"free slot": {
+			input: input{completeAts: []int64{50}, slots: 2, now: 10},
+			want:  10,
+		},
+		"earliest free slot": {
+			input: input{completeAts: []int64{50, 20, 30}, slots: 2, now: 10},
+			want:  30,
+		},
+		"completed but not yet removed": {
+			input: input{completeAts: []int64{5, 8}, slots: 2, now: 10},
+			want:  10,
+		},
+		"invalid slots": {
+			input: input{completeAts: []int64{20}, slots: 0, now: 10},
+			want:  20,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			queue := make([]*Construction, len(test.input.completeAts))
+			for i, completeAt := range test.input.completeAts {
+				queue[i] = &Construction{CompleteAt: completeAt}
+			}
+
+			got := GetConstructionStartTime(queue, test.input.slots, test.input.now)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetConstructionStartTime(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -8,6 +8,13 @@ import (
 
 const ThiefSpeed = 5 * time.Minute
 const ThiefCapacity = 4_000
+const ThiefPizzaCapacity = 2_000
+const ThiefStealth = 2
+const ScoutSpeed = 3 * time.Minute
+const SaboteurSpeed = 5 * time.Minute
+const CourierSpeed = 4 * time.Minute
+const CourierCapacity = 2_000
+const GuardSpeed = 6 * time.Minute
 
 var FullGameData = GameData{
 	Buildings: map[int32]*BuildingInfo{
@@ -141,6 +148,34 @@ var FullGameData = GameData{
 				{
 					Cost:             30_000,
 					ConstructionTime: 3600,
+					Trainer: &Trainer{
+						Capacity: 10,
+						Speed:    1.0,
+					},
+				},
+				{
+					Cost:             60_000,
+					ConstructionTime: 4 * 3600,
+					Trainer: &Trainer{
+						Capacity: 25,
+						Speed:    1.25,
+					},
+				},
+				{
+					Cost:             140_000,
+					ConstructionTime: 12 * 3600,
+					Trainer: &Trainer{
+						Capacity: 50,
+						Speed:    1.5,
+					},
+				},
+				{
+					Cost:             320_000,
+					ConstructionTime: 30 * 3600,
+					Trainer: &Trainer{
+						Capacity: 100,
+						Speed:    2.0,
+					},
 				},
 			},
 		},
@@ -185,6 +220,95 @@ var FullGameData = GameData{
 				{
 					Cost:             200_000,
 					ConstructionTime: 9600,
+					Researcher: &Researcher{
+						Slots: 1,
+						Speed: 1.0,
+					},
+				},
+				{
+					Cost:             450_000,
+					ConstructionTime: 16 * 3600,
+					Researcher: &Researcher{
+						Slots: 1,
+						Speed: 1.25,
+					},
+				},
+				{
+					Cost:             1_000_000,
+					ConstructionTime: 36 * 3600,
+					Researcher: &Researcher{
+						Slots: 2,
+						Speed: 1.5,
+					},
+				},
+				{
+					Cost:             2_200_000,
+					ConstructionTime: 72 * 3600,
+					Researcher: &Researcher{
+						Slots: 2,
+						Speed: 2.0,
+					},
+				},
+			},
+		},
+		int32(Building_BUILDERS_GUILD): {
+			Title:       "Builder's Guild",
+			TitlePlural: "Builder's Guilds",
+			LevelInfos: []*BuildingInfo_LevelInfo{
+				{
+					Cost:             75_000,
+					ConstructionTime: 3 * 3600,
+					Builder: &Builder{
+						ConstructionSlots: 1,
+					},
+				},
+				{
+					Cost:             250_000,
+					ConstructionTime: 12 * 3600,
+					Builder: &Builder{
+						ConstructionSlots: 2,
+					},
+				},
+				{
+					Cost:             800_000,
+					ConstructionTime: 36 * 3600,
+					Builder: &Builder{
+						ConstructionSlots: 3,
+					},
+				},
+			},
+		},
+		int32(Building_VAULT): {
+			Title:       "Vault",
+			TitlePlural: "Vaults",
+			LevelInfos: []*BuildingInfo_LevelInfo{
+				{
+					Cost:             30_000,
+					ConstructionTime: 1800,
+					Vault: &Vault{
+						ProtectedCoins: 10_000,
+					},
+				},
+				{
+					Cost:             100_000,
+					ConstructionTime: 4 * 3600,
+					Vault: &Vault{
+						ProtectedCoins: 40_000,
+					},
+				},
+				{
+					Cost:             300_000,
+					ConstructionTime: 12 * 3600,
+					Vault: &Vault{
+						ProtectedCoins: 120_000,
+					},
+				},
+				{
+					Cost:             900_000,
+					ConstructionTime: 36 * 3600,
+					Vault: &Vault{
+						ProtectedCoins: 350_000,
+					},
 				},
 			},
 		},
@@ -225,6 +349,20 @@ var FullGameData = GameData{
 			TrainTime:   1200,
 			Employer:    Building_MARKETINGHQ.Enum(),
 		},
+		int32(Education_SCOUT): {
+			Title:       "Scout",
+			TitlePlural: "Scouts",
+			Cost:        8_000,
+			TrainTime:   600,
+			Employer:    nil,
+		},
+		int32(Education_SABOTEUR): {
+			Title:       "Saboteur",
+			TitlePlural: "Saboteurs",
+			Cost:        25_000,
+			TrainTime:   2400,
+			Employer:    nil,
+		},
 	},
 	ResearchTracks: []*ResearchTrack{
 		{
@@ -271,6 +409,17 @@ var FullGameData = GameData{
 								Discovery:    ResearchDiscovery_HYBRID_OVEN,
 								Cost:         250_000,
 								ResearchTime: 3600 * 24,
+								Nodes: []*ResearchNode{
+									{
+										Title:        "Smart Oven",
+										Discovery:    ResearchDiscovery_SMART_OVEN,
+										Cost:         600_000,
+										ResearchTime: 3600 * 36,
+										Requirements: []ResearchDiscovery{
+											ResearchDiscovery_MOBILE_APP,
+										},
+									},
+								},
 							},
 						},
 					},
@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"math"
+	"sort"
+
+	"github.com/fnatte/pizza-tribes/internal/combat"
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Returns how much of the resource a single thief can carry
+func GetThiefCapacity(resource Resource) int32 {
+	if resource == Resource_PIZZAS {
+		return ThiefPizzaCapacity
+	}
+	return ThiefCapacity
+}
+
+// Returns how much of the resource that is left in the town for thieves to
+// take. Coins in vaults are out of reach.
+func CountStealable(gs *GameState, resource Resource) int32 {
+	if resource == Resource_PIZZAS {
+		if gs.Resources == nil {
+			return 0
+		}
+		return gs.Resources.Pizzas
+	}
+	return CountStealableCoins(gs)
+}
+
+// Returns how much of the resource the thieves get away with
+func CalculateLoot(gs *GameState, resource Resource, thieves int32) int64 {
+	return int64(MinInt32(thieves*GetThiefCapacity(resource), CountStealable(gs, resource)))
+}
+
+func GetResourceName(resource Resource) string {
+	if resource == Resource_PIZZAS {
+		return "pizzas"
+	}
+	return "coins"
+}
+
+// Guards assumed to defend a town that has not been scouted, per thief
+// sent on the heist
+const UnscoutedGuardsPerThief = 4
+
+// Number of values sampled from each uncertain range when previewing
+// heists
+const heistPreviewSamples = 11
+
+// Outcomes less likely than this are left out of heist previews
+const heistPreviewMinProbability = 1e-9
+
+// What the thieves know about the target of a heist. The values are ranges,
+// since scout reports are only approximate.
+type HeistIntel struct {
+	ScoutReportId string
+	GuardsMin     int32
+	GuardsMax     int32
+	GuardLevelMin int32
+	GuardLevelMax int32
+	StealableMin  int64
+	StealableMax  int64
+}
+
+// Returns the most recent report from scouting the town at x, y
+func FindLatestScoutReport(reports []*Report, x, y int32) *Report {
+	var latest *Report
+	for _, r := range reports {
+		sr := r.ScoutReport
+		if sr == nil || sr.X != x || sr.Y != y {
+			continue
+		}
+		if latest == nil || r.CreatedAt > latest.CreatedAt {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// Returns what is known about the target from the scout report. Without a
+// report, or for what scouts do not count, wide ranges are assumed. Scouts
+// never learn the level of the guards, so it can be anything.
+func GetHeistIntel(report *Report, resource Resource, thieves int32) HeistIntel {
+	intel := HeistIntel{
+		GuardsMin:     0,
+		GuardsMax:     thieves * UnscoutedGuardsPerThief,
+		GuardLevelMin: 0,
+		GuardLevelMax: combat.MaxLevel,
+		StealableMin:  0,
+		StealableMax:  int64(thieves) * int64(GetThiefCapacity(resource)),
+	}
+	if report == nil || report.ScoutReport == nil {
+		return intel
+	}
+
+	sr := report.ScoutReport
+	intel.ScoutReportId = report.Id
+	intel.GuardsMin = sr.GuardsMin
+	intel.GuardsMax = sr.GuardsMax
+
+	// Scouts count coins but not pizzas. The levels of the vaults are not
+	// known, so they might protect anything from the least to the most.
+	if resource == Resource_COINS {
+		var vaultsMin, vaultsMax int64
+		for _, b := range sr.Buildings {
+			if b.Building == Building_VAULT {
+				vaultsMin, vaultsMax = int64(b.Min), int64(b.Max)
+			}
+		}
+		levels := FullGameData.Buildings[int32(Building_VAULT)].LevelInfos
+		leastProtected := int64(levels[0].Vault.ProtectedCoins)
+		mostProtected := int64(levels[len(levels)-1].Vault.ProtectedCoins)
+		intel.StealableMin = Max(sr.CoinsMin-vaultsMax*mostProtected, 0)
+		intel.StealableMax = Max(sr.CoinsMax-vaultsMin*leastProtected, 0)
+	}
+
+	return intel
+}
+
+// Returns the chance of the outcome from the binomial distribution of
+// successful thieves
+func getBinomialProb(n int32, p float64, k int32) float64 {
+	if p <= 0 {
+		if k == 0 {
+			return 1
+		}
+		return 0
+	}
+	if p >= 1 {
+		if k == n {
+			return 1
+		}
+		return 0
+	}
+	return distuv.Binomial{N: float64(n), P: p}.Prob(float64(k))
+}
+
+// Returns evenly spaced values from the range, including both ends
+func sampleRange(min, max int64) []int64 {
+	if max < min {
+		min, max = max, min
+	}
+	n := Min(max-min+1, heistPreviewSamples)
+	if n == 1 {
+		return []int64{min}
+	}
+	samples := make([]int64, n)
+	for i := range samples {
+		samples[i] = min + (max-min)*int64(i)/(n-1)
+	}
+	return samples
+}
+
+type weightedLoot struct {
+	loot   int64
+	weight float64
+}
+
+// Returns the loot at the quantile of the outcomes, which must be sorted by
+// loot
+func getLootQuantile(outcomes []weightedLoot, total float64, q float64) int64 {
+	cumulative := 0.0
+	for _, o := range outcomes {
+		cumulative = cumulative + o.weight
+		if cumulative >= q*total {
+			return o.loot
+		}
+	}
+	return outcomes[len(outcomes)-1].loot
+}
+
+// Previews the outcome of a heist using the same model as when the thieves
+// arrive. Every guard count, guard level and amount of stealable resources
+// in the ranges of the intel is considered equally likely.
+func PreviewHeist(intel HeistIntel, resource Resource, thieves int32, thiefLevel int32) *HeistPreview {
+	preview := &HeistPreview{
+		Thieves:          thieves,
+		Resource:         resource,
+		ScoutReportId:    intel.ScoutReportId,
+		GuardsMin:        intel.GuardsMin,
+		GuardsMax:        intel.GuardsMax,
+		GuardLevelMin:    intel.GuardLevelMin,
+		GuardLevelMax:    intel.GuardLevelMax,
+		StealableMin:     intel.StealableMin,
+		StealableMax:     intel.StealableMax,
+		SuccessChanceMin: 1,
+		SuccessChanceMax: 0,
+		Loot:             &HeistPreview_LootDistribution{},
+	}
+
+	capacity := int64(GetThiefCapacity(resource))
+	guardSamples := sampleRange(int64(intel.GuardsMin), int64(intel.GuardsMax))
+	guardLevelSamples := sampleRange(int64(intel.GuardLevelMin), int64(intel.GuardLevelMax))
+	stealableSamples := sampleRange(intel.StealableMin, intel.StealableMax)
+	sampleWeight := 1 / float64(len(guardSamples)*len(guardLevelSamples)*len(stealableSamples))
+
+	outcomes := []weightedLoot{}
+	for _, guards := range guardSamples {
+		for _, guardLevel := range guardLevelSamples {
+			p := combat.GetSuccessChance(combat.Engagement{
+				Attackers:     thieves,
+				AttackerLevel: thiefLevel,
+				Guards:        int32(guards),
+				GuardLevel:    int32(guardLevel),
+				Stealth:       ThiefStealth,
+			})
+			preview.SuccessChanceMin = math.Min(preview.SuccessChanceMin, p)
+			preview.SuccessChanceMax = math.Max(preview.SuccessChanceMax, p)
+
+			for k := int32(0); k <= thieves; k++ {
+				pk := getBinomialProb(thieves, p, k)
+				if pk < heistPreviewMinProbability {
+					continue
+				}
+				for _, stealable := range stealableSamples {
+					outcomes = append(outcomes, weightedLoot{
+						loot:   Min(int64(k)*capacity, stealable),
+						weight: pk * sampleWeight,
+					})
+				}
+			}
+		}
+	}
+
+	if len(outcomes) == 0 {
+		return preview
+	}
+
+	sort.Slice(outcomes, func(i, j int) bool {
+		return outcomes[i].loot < outcomes[j].loot
+	})
+
+	total := 0.0
+	mean := 0.0
+	for _, o := range outcomes {
+		total = total + o.weight
+		mean = mean + float64(o.loot)*o.weight
+	}
+
+	preview.Loot.Mean = int64(math.Round(mean / total))
+	preview.Loot.Min = outcomes[0].loot
+	preview.Loot.P10 = getLootQuantile(outcomes, total, 0.1)
+	preview.Loot.P50 = getLootQuantile(outcomes, total, 0.5)
+	preview.Loot.P90 = getLootQuantile(outcomes, total, 0.9)
+	preview.Loot.Max = outcomes[len(outcomes)-1].loot
+
+	return preview
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/fnatte/pizza-tribes/internal/combat"
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestCalculateLoot(t *testing.T) {
+	gs := &GameState{
+		Resources: &GameState_Resources{Coins: 50_000, Pizzas: 5_000},
+		Lots: map[string]*GameState_Lot{
+			"1": {Building: Building_VAULT, Level: 0},
+		},
+	}
+
+	tests := map[string]struct {
+		resource Resource
+		thieves  int32
+		want     int64
+	}{
+		"coins at capacity":     {resource: Resource_COINS, thieves: 2, want: 2 * ThiefCapacity},
+		"coins above vault":     {resource: Resource_COINS, thieves: 100, want: 40_000},
+		"pizzas at capacity":    {resource: Resource_PIZZAS, thieves: 1, want: ThiefPizzaCapacity},
+		"all pizzas":            {resource: Resource_PIZZAS, thieves: 100, want: 5_000},
+		"no successful thieves": {resource: Resource_PIZZAS, thieves: 0, want: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CalculateLoot(gs, test.resource, test.thieves)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CalculateLoot() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetHeistIntel(t *testing.T) {
+	report := &Report{
+		Id: "r1",
+		ScoutReport: &ScoutReport{
+			GuardsMin: 5,
+			GuardsMax: 8,
+			CoinsMin:  400_000,
+			CoinsMax:  500_000,
+			Buildings: []*ScoutReport_BuildingCount{
+				{Building: Building_VAULT, Min: 1, Max: 1},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		report   *Report
+		resource Resource
+		want     HeistIntel
+	}{
+		"not scouted": {
+			report:   nil,
+			resource: Resource_COINS,
+			want: HeistIntel{
+				GuardsMax:     10 * UnscoutedGuardsPerThief,
+				GuardLevelMax: combat.MaxLevel,
+				StealableMax:  10 * ThiefCapacity,
+			},
+		},
+		"scouted coins": {
+			report:   report,
+			resource: Resource_COINS,
+			want: HeistIntel{
+				ScoutReportId: "r1",
+				GuardsMin:     5,
+				GuardsMax:     8,
+				GuardLevelMax: combat.MaxLevel,
+				StealableMin:  50_000,
+				StealableMax:  490_000,
+			},
+		},
+		"scouted pizzas": {
+			report:   report,
+			resource: Resource_PIZZAS,
+			want: HeistIntel{
+				ScoutReportId: "r1",
+				GuardsMin:     5,
+				GuardsMax:     8,
+				GuardLevelMax: combat.MaxLevel,
+				StealableMax:  10 * ThiefPizzaCapacity,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetHeistIntel(test.report, test.resource, 10)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetHeistIntel() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindLatestScoutReport(t *testing.T) {
+	reports := []*Report{
+		{Id: "a", CreatedAt: 1, ScoutReport: &ScoutReport{X: 1, Y: 2}},
+		{Id: "b", CreatedAt: 3, ScoutReport: &ScoutReport{X: 1, Y: 2}},
+		{Id: "c", CreatedAt: 4, ScoutReport: &ScoutReport{X: 2, Y: 2}},
+		{Id: "d", CreatedAt: 5},
+	}
+
+	got := FindLatestScoutReport(reports, 1, 2)
+	if diff := cmp.Diff("b", got.GetId()); diff != "" {
+		t.Errorf("FindLatestScoutReport() mismatch (-want +got):\n%s", diff)
+	}
+	if got = FindLatestScoutReport(reports, 9, 9); got != nil {
+		t.Errorf("FindLatestScoutReport() = %v, want nil", got)
+	}
+}
+
+func TestPreviewHeist(t *testing.T) {
+	tests := map[string]struct {
+		intel HeistIntel
+		want  *HeistPreview_LootDistribution
+	}{
+		"no guards": {
+			intel: HeistIntel{StealableMin: 100_000, StealableMax: 100_000},
+			want: &HeistPreview_LootDistribution{
+				Mean: 10 * ThiefCapacity,
+				Min:  10 * ThiefCapacity,
+				P10:  10 * ThiefCapacity,
+				P50:  10 * ThiefCapacity,
+				P90:  10 * ThiefCapacity,
+				Max:  10 * ThiefCapacity,
+			},
+		},
+		"nothing to steal": {
+			intel: HeistIntel{GuardsMin: 5, GuardsMax: 20},
+			want:  &HeistPreview_LootDistribution{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := PreviewHeist(test.intel, Resource_COINS, 10, 0).Loot
+			if diff := cmp.Diff(test.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("PreviewHeist() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// More guards should never make the heist look better
+	few := PreviewHeist(HeistIntel{GuardsMin: 5, GuardsMax: 5, StealableMax: 100_000}, Resource_COINS, 10, 0)
+	many := PreviewHeist(HeistIntel{GuardsMin: 50, GuardsMax: 50, StealableMax: 100_000}, Resource_COINS, 10, 0)
+	if many.Loot.Mean >= few.Loot.Mean {
+		t.Errorf("PreviewHeist() mean loot with many guards %d >= with few guards %d", many.Loot.Mean, few.Loot.Mean)
+	}
+	if !(few.Loot.P10 <= few.Loot.P50 && few.Loot.P50 <= few.Loot.P90) {
+		t.Errorf("PreviewHeist() percentiles out of order: %v", few.Loot)
+	}
+
+	// Guards of unknown level can be stronger than guards of level 0
+	unknownLevel := PreviewHeist(HeistIntel{GuardsMin: 5, GuardsMax: 5, GuardLevelMax: combat.MaxLevel, StealableMax: 100_000}, Resource_COINS, 10, 0)
+	if unknownLevel.SuccessChanceMin >= few.SuccessChanceMin {
+		t.Errorf("PreviewHeist() success chance with unknown guard level %f >= with level 0 %f", unknownLevel.SuccessChanceMin, few.SuccessChanceMin)
+	}
+	if diff := cmp.Diff(few.SuccessChanceMax, unknownLevel.SuccessChanceMax); diff != "" {
+		t.Errorf("PreviewHeist() max success chance mismatch (-want +got):\n%s", diff)
+	}
+}
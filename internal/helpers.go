@@ -1,9 +1,14 @@
 package internal
 
 import (
+	"context"
+	"fmt"
+	"sort"
 	"time"
 
 	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 func NewInt64(i int64) *int64    { return &i }
@@ -46,9 +51,56 @@ func MinInt32(a, b int32) int32 {
 	return b
 }
 
+func MaxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Returns true if the building on the lot has been disabled (e.g. by
+// saboteurs). A disabled building has no effect until it is repaired.
+func IsLotDisabled(lot *GameState_Lot, now int64) bool {
+	return lot.DisabledUntil > now
+}
+
+// Returns true if saboteurs can damage the lot. Buildings that have been
+// upgraded can lose a level, while the other buildings are disabled, which
+// only has an effect on buildings that do their work while enabled. An
+// unupgraded house is not affected by either.
+func CanSabotageLot(lot *GameState_Lot) bool {
+	info := FullGameData.Buildings[int32(lot.Building)]
+	if info == nil || int(lot.Level) >= len(info.LevelInfos) {
+		return false
+	}
+	levelInfo := info.LevelInfos[lot.Level]
+	if levelInfo.Employer == nil && lot.Level > 0 {
+		return true
+	}
+	return levelInfo.Employer != nil ||
+		levelInfo.Builder != nil ||
+		levelInfo.Trainer != nil ||
+		levelInfo.Researcher != nil ||
+		levelInfo.Vault != nil
+}
+
+// Returns the JSON of the lot after it has been tapped. The whole lot is
+// written, rather than just the tapped at field, so that the lot only ever
+// holds the field names written by protojson. A lot with both tapped_at and
+// tappedAt can not be unmarshalled.
+func MarshalTappedLot(lot *GameState_Lot, now int64) ([]byte, error) {
+	tapped := proto.Clone(lot).(*GameState_Lot)
+	tapped.TappedAt = now
+	return protojson.Marshal(tapped)
+}
+
 func CountMaxEmployed(gs *GameState) (counts map[int32]int32) {
 	counts = map[int32]int32{}
+	now := time.Now().UnixNano()
 	for _, lot := range gs.Lots {
+		if IsLotDisabled(lot, now) {
+			continue
+		}
 		info := FullGameData.Buildings[int32(lot.Building)]
 		if info != nil && info.LevelInfos[lot.Level].Employer != nil {
 			counts[int32(lot.Building)] = counts[int32(lot.Building)] +
@@ -79,13 +131,15 @@ func CountTownPopulation(population *GameState_Population) int32 {
 		population.Salesmice +
 		population.Guards +
 		population.Thieves +
-		population.Publicists)
+		population.Publicists +
+		population.Scouts +
+		population.Saboteurs)
 }
 
 func CountTravellingPopulation(travelQueue []*Travel) int32 {
 	var count int32 = 0
 	for _, t := range travelQueue {
-		count = count + t.Thieves
+		count = count + t.Thieves + t.Scouts + t.Saboteurs + t.Couriers + t.Guards
 	}
 
 	return count
@@ -107,21 +161,65 @@ func CountAllPopulation(gs *GameState) int32 {
 
 	return CountTownPopulation(gs.Population) +
 		CountTravellingPopulation(gs.TravelQueue) +
-		CountTrainingPopulation(gs.TrainingQueue)
+		CountTrainingPopulation(gs.TrainingQueue) +
+		CountStationedGuards(gs) +
+		CountInjuredGuards(gs)
 
 }
 
-func GetCompletedTravels(gs *GameState) (res []*Travel) {
-	now := time.Now().UnixNano()
+// Returns the time (unix nano) when a new item can be started given the
+// completion times of the items occupying the slots.
+//
+// Every item occupies a slot until it completes. New items are always
+// scheduled into the earliest free slot, so the slots become free at the
+// times of the latest n completions (where n is the number of slots).
+// If there are fewer items than slots, the item can be started immediately.
+func getSlotStartTime(completeAts []int64, slots int32, now int64) int64 {
+	if slots < 1 {
+		slots = 1
+	}
+	if len(completeAts) < int(slots) {
+		return now
+	}
+
+	sorted := append([]int64{}, completeAts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
 
-	for _, t := range gs.TravelQueue {
-		if t.ArrivalAt > now {
-			break
+	return Max(now, sorted[slots-1])
+}
+
+// Locks and reads the game states of the users and calls f with them. The
+// locks are obtained in a fixed order, so that two calls can not end up
+// waiting for each other.
+func withGameStates(ctx context.Context, r RedisClient, userIds []string, f func(gss map[string]*GameState) error) error {
+	ids := append([]string{}, userIds...)
+	sort.Strings(ids)
+
+	gss := map[string]*GameState{}
+	for _, userId := range ids {
+		if gss[userId] != nil {
+			continue
+		}
+		gsKey := fmt.Sprintf("user:%s:gamestate", userId)
+
+		mutex := r.NewMutex("lock:" + gsKey)
+		if err := mutex.Lock(); err != nil {
+			return fmt.Errorf("failed to obtain lock: %w", err)
 		}
+		defer mutex.Unlock()
 
-		res = append(res, t)
+		str, err := RedisJsonGet(r, ctx, gsKey, ".").Result()
+		if err != nil {
+			return fmt.Errorf("failed to get game state: %w", err)
+		}
+		gs := &GameState{}
+		if err = protojson.Unmarshal([]byte(str), gs); err != nil {
+			return fmt.Errorf("failed to unmarshal game state: %w", err)
+		}
+		gss[userId] = gs
 	}
 
-	return res
+	return f(gss)
 }
-
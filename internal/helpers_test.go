@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestMarshalTappedLot(t *testing.T) {
+	// The updater writes sabotaged lots from a lot patch
+	sabotaged, err := protojson.Marshal(&GameStatePatch_LotPatch{
+		Building:      Building_KITCHEN,
+		TappedAt:      100,
+		Level:         1,
+		DisabledUntil: 500,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		lot  string
+		want *GameState_Lot
+	}{
+		"sabotaged": {
+			lot:  string(sabotaged),
+			want: &GameState_Lot{Building: Building_KITCHEN, TappedAt: 1000, Level: 1, DisabledUntil: 500},
+		},
+		"tapped before field names were unified": {
+			lot:  `{"building": "SHOP", "tapped_at": "100"}`,
+			want: &GameState_Lot{Building: Building_SHOP, TappedAt: 1000},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			lot := &GameState_Lot{}
+			if err := protojson.Unmarshal([]byte(test.lot), lot); err != nil {
+				t.Fatalf("Unmarshal() of lot error = %v", err)
+			}
+
+			b, err := MarshalTappedLot(lot, 1000)
+			if err != nil {
+				t.Fatalf("MarshalTappedLot() error = %v", err)
+			}
+
+			// The tapped lot replaces the whole lot in the game state
+			gs := &GameState{}
+			str := fmt.Sprintf(`{"lots": {"1": %s}}`, b)
+			if err := protojson.Unmarshal([]byte(str), gs); err != nil {
+				t.Fatalf("Unmarshal() of game state error = %v", err)
+			}
+			if diff := cmp.Diff(test.want, gs.Lots["1"], protocmp.Transform()); diff != "" {
+				t.Errorf("MarshalTappedLot() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCanSabotageLot(t *testing.T) {
+	tests := map[string]struct {
+		lot  *GameState_Lot
+		want bool
+	}{
+		"kitchen":         {lot: &GameState_Lot{Building: Building_KITCHEN}, want: true},
+		"house":           {lot: &GameState_Lot{Building: Building_HOUSE}, want: false},
+		"upgraded house":  {lot: &GameState_Lot{Building: Building_HOUSE, Level: 1}, want: true},
+		"school":          {lot: &GameState_Lot{Building: Building_SCHOOL}, want: true},
+		"builder's guild": {lot: &GameState_Lot{Building: Building_BUILDERS_GUILD}, want: true},
+		"vault":           {lot: &GameState_Lot{Building: Building_VAULT}, want: true},
+		"unknown level":   {lot: &GameState_Lot{Building: Building_HOUSE, Level: 100}, want: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CanSabotageLot(test.lot)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CanSabotageLot() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+)
+
+// Returns the chance that the guards of a town spot thieves on their way
+// to it. Towns without guards never see anyone coming.
+func GetDetectionChance(guards int32, thieves int32) float64 {
+	if guards <= 0 {
+		return 0
+	}
+	return float64(guards) / float64(guards+thieves)
+}
+
+func getIncomingTravelPath(travelId string) string {
+	return fmt.Sprintf(".incomingTravels[\"%s\"]", travelId)
+}
+
+// Adds a spotted travel to the incoming travels of the user
+func AddIncomingTravel(ctx context.Context, r RedisClient, userId string, travel *IncomingTravel) error {
+	gsKey := fmt.Sprintf("user:%s:gamestate", userId)
+
+	b, err := protojson.Marshal(travel)
+	if err != nil {
+		return fmt.Errorf("failed to marshal incoming travel: %w", err)
+	}
+
+	if err = RedisJsonEnsureObject(r, ctx, gsKey, ".incomingTravels"); err != nil {
+		return err
+	}
+
+	err = RedisJsonSet(r, ctx, gsKey, getIncomingTravelPath(travel.Id), b).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add incoming travel: %w", err)
+	}
+
+	return nil
+}
+
+// Removes a spotted travel from the incoming travels of the user. Returns
+// true if the travel had been spotted.
+func RemoveIncomingTravel(ctx context.Context, r RedisProcesser, userId string, travelId string) (bool, error) {
+	gsKey := fmt.Sprintf("user:%s:gamestate", userId)
+	n, err := RedisJsonDel(r, ctx, gsKey, getIncomingTravelPath(travelId)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove incoming travel: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Returns the ids of the incoming travels that should have arrived at the
+// time now
+func GetExpiredIncomingTravels(gs *GameState, now int64) []string {
+	ids := []string{}
+	for id, t := range gs.IncomingTravels {
+		if t.ArrivalAt <= now {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetDetectionChance(t *testing.T) {
+	tests := map[string]struct {
+		guards  int32
+		thieves int32
+		want    float64
+	}{
+		"no guards":     {guards: 0, thieves: 10, want: 0},
+		"equal numbers": {guards: 10, thieves: 10, want: 0.5},
+		"more guards":   {guards: 30, thieves: 10, want: 0.75},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetDetectionChance(test.guards, test.thieves)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetDetectionChance(%d, %d) mismatch (-want +got):\n%s", test.guards, test.thieves, diff)
+			}
+		})
+	}
+}
+
+func TestGetExpiredIncomingTravels(t *testing.T) {
+	gs := &GameState{
+		IncomingTravels: map[string]*IncomingTravel{
+			"c": {Id: "c", ArrivalAt: 100},
+			"a": {Id: "a", ArrivalAt: 200},
+			"b": {Id: "b", ArrivalAt: 300},
+		},
+	}
+
+	got := GetExpiredIncomingTravels(gs, 200)
+	if diff := cmp.Diff([]string{"a", "c"}, got); diff != "" {
+		t.Errorf("GetExpiredIncomingTravels() mismatch (-want +got):\n%s", diff)
+	}
+}
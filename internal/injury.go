@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+)
+
+// Time it takes for injured guards to get back on duty
+const GuardRecoveryTime = 2 * time.Hour
+
+// Returns the number of guards that are recovering from injuries
+func CountInjuredGuards(gs *GameState) int32 {
+	var count int32 = 0
+	for _, injury := range gs.Injuries {
+		count = count + injury.Guards
+	}
+	return count
+}
+
+// Returns the ids of the injuries that have been recovered from at the
+// time now
+func GetRecoveredInjuries(gs *GameState, now int64) []string {
+	ids := []string{}
+	for id, injury := range gs.Injuries {
+		if injury.RecoveredAt <= now {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func getInjuryPath(id string) string {
+	return fmt.Sprintf(".injuries[\"%s\"]", id)
+}
+
+// Writes the injury to the game state. The injuries map must exist.
+func SaveInjury(ctx context.Context, pipe redis.Pipeliner, gsKey string, id string, injury *Injury) error {
+	b, err := protojson.Marshal(injury)
+	if err != nil {
+		return fmt.Errorf("failed to marshal injury: %w", err)
+	}
+	if err = RedisJsonSet(pipe, ctx, gsKey, getInjuryPath(id), b).Err(); err != nil {
+		return fmt.Errorf("failed to write injury: %w", err)
+	}
+	return nil
+}
+
+// Removes the injury from the game state
+func RemoveInjury(ctx context.Context, pipe redis.Pipeliner, gsKey string, id string) error {
+	if err := RedisJsonDel(pipe, ctx, gsKey, getInjuryPath(id)).Err(); err != nil {
+		return fmt.Errorf("failed to remove injury: %w", err)
+	}
+	return nil
+}
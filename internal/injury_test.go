@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetRecoveredInjuries(t *testing.T) {
+	gs := &GameState{
+		Injuries: map[string]*Injury{
+			"c": {Guards: 1, RecoveredAt: 100},
+			"a": {Guards: 2, RecoveredAt: 200},
+			"b": {Guards: 4, RecoveredAt: 300},
+		},
+	}
+
+	got := GetRecoveredInjuries(gs, 200)
+	if diff := cmp.Diff([]string{"a", "c"}, got); diff != "" {
+		t.Errorf("GetRecoveredInjuries() mismatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(int32(7), CountInjuredGuards(gs)); diff != "" {
+		t.Errorf("CountInjuredGuards() mismatch (-want +got):\n%s", diff)
+	}
+}
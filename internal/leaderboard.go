@@ -27,6 +27,9 @@ func (s *LeaderboardService) Get(ctx context.Context, skip int) (*Leaderboard, e
 		Rows: make([]*Leaderboard_Row, len(res)),
 	}
 
+	tribes := NewTribeService(s.r)
+	tribeTags := map[string]string{}
+
 	for i, row := range res {
 		userId := row.Member.(string)
 		userKey := fmt.Sprintf("user:%s", userId)
@@ -35,10 +38,23 @@ func (s *LeaderboardService) Get(ctx context.Context, skip int) (*Leaderboard, e
 			return nil, fmt.Errorf("failed to get username: %w", err)
 		}
 
+		tribeId, err := tribes.GetUserTribeId(ctx, userId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tribe: %w", err)
+		}
+		if _, ok := tribeTags[tribeId]; tribeId != "" && !ok {
+			tribe, err := tribes.Get(ctx, tribeId)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get tribe: %w", err)
+			}
+			tribeTags[tribeId] = tribe.Tag
+		}
+
 		board.Rows[i] = &Leaderboard_Row{
 			UserId:   userId,
 			Coins:    int64(row.Score),
 			Username: username,
+			TribeTag: tribeTags[tribeId],
 		}
 	}
 
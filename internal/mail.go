@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+)
+
+const LetterMaxSubjectLength = 80
+const LetterMaxContentLength = 2000
+
+// How many letters a user can send per day
+const LetterDailyQuota = 20
+
+// How many of the latest letters are sent to the user
+const LetterPageSize = 20
+
+func getMailKeys(userId string) (mailKey, mailIndexKey, unreadKey string) {
+	mailKey = fmt.Sprintf("user:%s:mail", userId)
+	mailIndexKey = fmt.Sprintf("user:%s:mailByDate", userId)
+	unreadKey = fmt.Sprintf("user:%s:mailUnread", userId)
+	return
+}
+
+func SaveLetter(ctx context.Context, r redis.Cmdable, userId string, letter *Letter) error {
+	b, err := protojson.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal letter: %w", err)
+	}
+
+	mailKey, mailIndexKey, unreadKey := getMailKeys(userId)
+
+	pipe := r.TxPipeline()
+	pipe.HSet(ctx, mailKey, letter.Id, b)
+	pipe.ZAdd(ctx, mailIndexKey, &redis.Z{
+		Score:  float64(letter.CreatedAt),
+		Member: letter.Id,
+	})
+	if letter.Unread {
+		pipe.SAdd(ctx, unreadKey, letter.Id)
+	} else {
+		pipe.SRem(ctx, unreadKey, letter.Id)
+	}
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save letter: %w", err)
+	}
+
+	return nil
+}
+
+func GetLetter(ctx context.Context, r redis.Cmdable, userId string, letterId string) (*Letter, error) {
+	mailKey, _, _ := getMailKeys(userId)
+	str, err := r.HGet(ctx, mailKey, letterId).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get letter: %w", err)
+	}
+
+	letter := &Letter{}
+	if err = protojson.Unmarshal([]byte(str), letter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal letter: %w", err)
+	}
+
+	return letter, nil
+}
+
+// Returns the latest letters in the mailbox of the user, newest first
+func GetLetters(ctx context.Context, r redis.Cmdable, userId string) ([]*Letter, error) {
+	mailKey, mailIndexKey, _ := getMailKeys(userId)
+
+	ids, err := r.ZRevRange(ctx, mailIndexKey, 0, LetterPageSize-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from mail index: %w", err)
+	}
+	if len(ids) == 0 {
+		return []*Letter{}, nil
+	}
+	res, err := r.HMGet(ctx, mailKey, ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read letters: %w", err)
+	}
+
+	letters := make([]*Letter, 0, len(res))
+	for _, b := range res {
+		str, ok := b.(string)
+		if !ok {
+			continue
+		}
+		letter := &Letter{}
+		if err = protojson.Unmarshal([]byte(str), letter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+func CountUnreadLetters(ctx context.Context, r redis.Cmdable, userId string) (int64, error) {
+	_, _, unreadKey := getMailKeys(userId)
+	return r.SCard(ctx, unreadKey).Result()
+}
+
+func MarkLetterAsRead(ctx context.Context, r redis.Cmdable, userId string, letterId string) error {
+	letter, err := GetLetter(ctx, r, userId, letterId)
+	if err != nil {
+		return err
+	}
+	letter.Unread = false
+	return SaveLetter(ctx, r, userId, letter)
+}
+
+// Archived letters are kept, but are no longer unread
+func ArchiveLetter(ctx context.Context, r redis.Cmdable, userId string, letterId string) error {
+	letter, err := GetLetter(ctx, r, userId, letterId)
+	if err != nil {
+		return err
+	}
+	letter.Unread = false
+	letter.Archived = true
+	return SaveLetter(ctx, r, userId, letter)
+}
+
+func DeleteLetter(ctx context.Context, r redis.Cmdable, userId string, letterId string) error {
+	mailKey, mailIndexKey, unreadKey := getMailKeys(userId)
+
+	pipe := r.TxPipeline()
+	pipe.HDel(ctx, mailKey, letterId)
+	pipe.ZRem(ctx, mailIndexKey, letterId)
+	pipe.SRem(ctx, unreadKey, letterId)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete letter: %w", err)
+	}
+
+	return nil
+}
+
+// Counts a sent letter against the daily quota of the user and returns an
+// error if the quota has been used up.
+func useLetterQuota(ctx context.Context, r redis.Cmdable, userId string) error {
+	key := fmt.Sprintf("user:%s:mailQuota:%s", userId, time.Now().UTC().Format("2006-01-02"))
+
+	// The counter is created together with its expiry, so that it can never
+	// be left without one
+	var incr *redis.IntCmd
+	_, err := r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key, 0, 24*time.Hour)
+		incr = pipe.Incr(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check letter quota: %w", err)
+	}
+	if incr.Val() > LetterDailyQuota {
+		return errors.New("daily letter quota exceeded")
+	}
+	return nil
+}
+
+// Sends a letter to the user with the username. Returns the letter, which
+// has no recipient id if the recipient ignores the sender, in which case the
+// letter is silently dropped.
+func SendLetter(ctx context.Context, r redis.Cmdable, senderId string, username string, subject string, content string, inReplyTo string) (*Letter, error) {
+	subject = strings.TrimSpace(subject)
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("letter is empty")
+	}
+	if utf8.RuneCountInString(subject) > LetterMaxSubjectLength {
+		return nil, errors.New("letter subject is too long")
+	}
+	if utf8.RuneCountInString(content) > LetterMaxContentLength {
+		return nil, errors.New("letter is too long")
+	}
+
+	recipientId, err := r.Get(ctx, fmt.Sprintf("username:%s", strings.ToLower(username))).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if recipientId == senderId {
+		return nil, errors.New("can't send letter to self")
+	}
+
+	senderUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", senderId), "username").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get username: %w", err)
+	}
+	recipientUsername, err := r.HGet(ctx, fmt.Sprintf("user:%s", recipientId), "username").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get username: %w", err)
+	}
+
+	if err = useLetterQuota(ctx, r, senderId); err != nil {
+		return nil, err
+	}
+
+	letter := &Letter{
+		Id:                xid.New().String(),
+		CreatedAt:         time.Now().UnixNano(),
+		SenderId:          senderId,
+		SenderUsername:    senderUsername,
+		RecipientId:       recipientId,
+		RecipientUsername: recipientUsername,
+		Subject:           subject,
+		Content:           content,
+		Unread:            true,
+		InReplyTo:         inReplyTo,
+	}
+
+	// Users that ignore the sender in the chat do not get letters either
+	ignored, err := r.SIsMember(ctx, getUserIgnoredKey(recipientId), senderId).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignored users: %w", err)
+	}
+	if ignored {
+		letter.RecipientId = ""
+		return letter, nil
+	}
+
+	if err = SaveLetter(ctx, r, recipientId, letter); err != nil {
+		return nil, err
+	}
+
+	return letter, nil
+}
+
+// Replies to a letter in the mailbox of the user
+func ReplyLetter(ctx context.Context, r redis.Cmdable, userId string, letterId string, content string) (*Letter, error) {
+	letter, err := GetLetter(ctx, r, userId, letterId)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := letter.Subject
+	if !strings.HasPrefix(subject, "Re: ") {
+		subject = "Re: " + subject
+	}
+	if utf8.RuneCountInString(subject) > LetterMaxSubjectLength {
+		subject = letter.Subject
+	}
+
+	return SendLetter(ctx, r, userId, letter.SenderUsername, subject, content, letter.Id)
+}
@@ -0,0 +1,508 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+	"golang.org/x/text/message"
+	"google.golang.org/protobuf/proto"
+)
+
+// Part of the coins of every trade that is taken from the seller. The fee is
+// not given to anyone, which makes the market a coin sink.
+const MarketFeeRate = 0.05
+
+// How long an order stays in the market before it expires
+const MarketOrderDuration = 24 * time.Hour
+
+// How many open orders a user can have at the same time
+const MarketMaxOrdersPerUser = 10
+
+const marketOrdersKey = "market:orders"
+const marketExpiryKey = "market:expiry"
+
+var marketPrinter = message.NewPrinter(message.MatchLanguage("en"))
+
+func getMarketSideKey(side MarketOrder_Side) string {
+	if side == MarketOrder_BUY {
+		return "market:buy"
+	}
+	return "market:sell"
+}
+
+func getUserMarketOrdersKey(userId string) string {
+	return fmt.Sprintf("user:%s:marketOrders", userId)
+}
+
+// Returns the score of the order in the sorted set of its side. The best
+// price always has the lowest score. Orders with the same price are sorted
+// by id, and since ids are ordered by creation time, older orders come first.
+func getMarketOrderScore(o *MarketOrder) float64 {
+	if o.Side == MarketOrder_BUY {
+		return float64(-o.Price)
+	}
+	return float64(o.Price)
+}
+
+type MarketTrade struct {
+	BuyOrder  *MarketOrder
+	SellOrder *MarketOrder
+	Price     int64
+	Amount    int32
+}
+
+// Returns the fee that the seller pays for selling pizzas for the coins
+func GetMarketFee(coins int64) int64 {
+	return int64(math.Ceil(float64(coins) * MarketFeeRate))
+}
+
+// Returns the coins and pizzas that must be held in escrow while the order
+// is open. Buyers pay up front, while sellers hand over their pizzas.
+func GetMarketEscrow(side MarketOrder_Side, price int64, amount int32) (coins int64, pizzas int64) {
+	if side == MarketOrder_BUY {
+		return price * int64(amount), 0
+	}
+	return 0, int64(amount)
+}
+
+// Matches the order against the orders on the other side of the market. The
+// book must be sorted by priority, i.e. best price first and then oldest
+// first. Trades are made at the price of the order that was already in the
+// book. Expired orders and orders from the same user are skipped.
+func MatchMarketOrder(order *MarketOrder, book []*MarketOrder, now int64) []*MarketTrade {
+	trades := []*MarketTrade{}
+	remaining := order.Amount
+
+	for _, o := range book {
+		if remaining <= 0 {
+			break
+		}
+		if o.UserId == order.UserId || o.ExpiresAt <= now || o.Amount <= 0 {
+			continue
+		}
+		if order.Side == MarketOrder_BUY && o.Price > order.Price {
+			break
+		}
+		if order.Side == MarketOrder_SELL && o.Price < order.Price {
+			break
+		}
+
+		trade := &MarketTrade{
+			Price:  o.Price,
+			Amount: MinInt32(remaining, o.Amount),
+		}
+		if order.Side == MarketOrder_BUY {
+			trade.BuyOrder, trade.SellOrder = order, o
+		} else {
+			trade.BuyOrder, trade.SellOrder = o, order
+		}
+		trades = append(trades, trade)
+		remaining = remaining - trade.Amount
+	}
+
+	return trades
+}
+
+type MarketService struct {
+	r RedisClient
+}
+
+func NewMarketService(r RedisClient) *MarketService {
+	return &MarketService{r: r}
+}
+
+func (s *MarketService) getOrdersByIds(ctx context.Context, ids []string) ([]*MarketOrder, error) {
+	if len(ids) == 0 {
+		return []*MarketOrder{}, nil
+	}
+
+	res, err := s.r.HMGet(ctx, marketOrdersKey, ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read market orders: %w", err)
+	}
+
+	orders := make([]*MarketOrder, 0, len(res))
+	for _, b := range res {
+		str, ok := b.(string)
+		if !ok {
+			continue
+		}
+		o := &MarketOrder{}
+		if err = protojson.Unmarshal([]byte(str), o); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal market order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+
+	return orders, nil
+}
+
+func (s *MarketService) getOrders(ctx context.Context, side MarketOrder_Side) ([]*MarketOrder, error) {
+	ids, err := s.r.ZRange(ctx, getMarketSideKey(side), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read market index: %w", err)
+	}
+
+	return s.getOrdersByIds(ctx, ids)
+}
+
+// Returns all open orders in the market, sorted by priority
+func (s *MarketService) GetOrderBook(ctx context.Context) (*MarketOrderBook, error) {
+	buys, err := s.getOrders(ctx, MarketOrder_BUY)
+	if err != nil {
+		return nil, err
+	}
+	sells, err := s.getOrders(ctx, MarketOrder_SELL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MarketOrderBook{
+		Buys:  buys,
+		Sells: sells,
+	}, nil
+}
+
+// Returns the open orders of the user, oldest first
+func (s *MarketService) GetUserOrders(ctx context.Context, userId string) ([]*MarketOrder, error) {
+	ids, err := s.r.SMembers(ctx, getUserMarketOrdersKey(userId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user market orders: %w", err)
+	}
+	sort.Strings(ids)
+
+	return s.getOrdersByIds(ctx, ids)
+}
+
+func saveMarketOrder(ctx context.Context, pipe redis.Pipeliner, o *MarketOrder) error {
+	b, err := protojson.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to marshal market order: %w", err)
+	}
+
+	pipe.HSet(ctx, marketOrdersKey, o.Id, b)
+	pipe.ZAdd(ctx, getMarketSideKey(o.Side), &redis.Z{
+		Score:  getMarketOrderScore(o),
+		Member: o.Id,
+	})
+	pipe.ZAdd(ctx, marketExpiryKey, &redis.Z{
+		Score:  float64(o.ExpiresAt),
+		Member: o.Id,
+	})
+	pipe.SAdd(ctx, getUserMarketOrdersKey(o.UserId), o.Id)
+
+	return nil
+}
+
+func removeMarketOrder(ctx context.Context, pipe redis.Pipeliner, o *MarketOrder) {
+	pipe.HDel(ctx, marketOrdersKey, o.Id)
+	pipe.ZRem(ctx, getMarketSideKey(o.Side), o.Id)
+	pipe.ZRem(ctx, marketExpiryKey, o.Id)
+	pipe.SRem(ctx, getUserMarketOrdersKey(o.UserId), o.Id)
+}
+
+// Saves the order if there are pizzas left in it, otherwise it is removed
+func updateMarketOrder(ctx context.Context, pipe redis.Pipeliner, o *MarketOrder) error {
+	if o.Amount <= 0 {
+		removeMarketOrder(ctx, pipe, o)
+		return nil
+	}
+	return saveMarketOrder(ctx, pipe, o)
+}
+
+// Adds the coins and pizzas to the game state. Negative values withdraw
+// resources, which fails if there is not enough of them.
+func setMarketResources(ctx context.Context, pipe redis.Pipeliner, userId string, gs *GameState, coins int64, pizzas int64) error {
+	if gs.Resources == nil {
+		gs.Resources = &GameState_Resources{}
+	}
+
+	newCoins := int64(gs.Resources.Coins) + coins
+	newPizzas := int64(gs.Resources.Pizzas) + pizzas
+	if newCoins < 0 {
+		return errors.New("not enough coins")
+	}
+	if newPizzas < 0 {
+		return errors.New("not enough pizzas")
+	}
+	if newCoins > math.MaxInt32 || newPizzas > math.MaxInt32 {
+		return errors.New("too many resources")
+	}
+
+	gsKey := fmt.Sprintf("user:%s:gamestate", userId)
+	if coins != 0 {
+		err := RedisJsonSet(pipe, ctx, gsKey, ".resources.coins", newCoins).Err()
+		if err != nil {
+			return fmt.Errorf("failed to set coins: %w", err)
+		}
+	}
+	if pizzas != 0 {
+		err := RedisJsonSet(pipe, ctx, gsKey, ".resources.pizzas", newPizzas).Err()
+		if err != nil {
+			return fmt.Errorf("failed to set pizzas: %w", err)
+		}
+	}
+
+	gs.Resources.Coins = int32(newCoins)
+	gs.Resources.Pizzas = int32(newPizzas)
+
+	return nil
+}
+
+func (s *MarketService) lockMarket() (func(), error) {
+	mutex := s.r.NewMutex("lock:market")
+	if err := mutex.Lock(); err != nil {
+		return nil, fmt.Errorf("failed to obtain market lock: %w", err)
+	}
+	return func() { mutex.Unlock() }, nil
+}
+
+// Places an order in the market. The offered coins or pizzas are moved from
+// the game state into escrow, and the order is then matched against the
+// orders on the other side. Returns the ids of all users that were affected.
+func (s *MarketService) PlaceOrder(ctx context.Context, userId string, side MarketOrder_Side, price int64, amount int32) ([]string, error) {
+	if price <= 0 {
+		return nil, errors.New("price must be greater than 0")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be greater than 0")
+	}
+	if price > math.MaxInt32/int64(amount) {
+		return nil, errors.New("order is too large")
+	}
+
+	unlock, err := s.lockMarket()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	count, err := s.r.SCard(ctx, getUserMarketOrdersKey(userId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count market orders: %w", err)
+	}
+	if count >= MarketMaxOrdersPerUser {
+		return nil, errors.New("too many open market orders")
+	}
+
+	now := time.Now().UnixNano()
+	order := &MarketOrder{
+		Id:        xid.New().String(),
+		UserId:    userId,
+		Side:      side,
+		Price:     price,
+		Amount:    amount,
+		CreatedAt: now,
+		ExpiresAt: now + MarketOrderDuration.Nanoseconds(),
+	}
+
+	// Put the offered resource in escrow
+	err = withGameStates(ctx, s.r, []string{userId}, func(gss map[string]*GameState) error {
+		coins, pizzas := GetMarketEscrow(side, price, amount)
+		_, err := s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if err := setMarketResources(ctx, pipe, userId, gss[userId], -coins, -pizzas); err != nil {
+				return err
+			}
+			return saveMarketOrder(ctx, pipe, order)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to place market order: %w", err)
+	}
+
+	otherSide := MarketOrder_SELL
+	if side == MarketOrder_SELL {
+		otherSide = MarketOrder_BUY
+	}
+	book, err := s.getOrders(ctx, otherSide)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := []string{userId}
+	for _, trade := range MatchMarketOrder(order, book, now) {
+		if err = s.settleTrade(ctx, trade); err != nil {
+			return affected, err
+		}
+		if side == MarketOrder_BUY {
+			affected = append(affected, trade.SellOrder.UserId)
+		} else {
+			affected = append(affected, trade.BuyOrder.UserId)
+		}
+	}
+
+	return affected, nil
+}
+
+// Moves the pizzas to the buyer and the coins, minus the fee, to the seller.
+// The buyer gets back any coins that were held in escrow over the trade
+// price. Both game states and orders are updated in the same transaction.
+func (s *MarketService) settleTrade(ctx context.Context, trade *MarketTrade) error {
+	buyerId := trade.BuyOrder.UserId
+	sellerId := trade.SellOrder.UserId
+
+	total := trade.Price * int64(trade.Amount)
+	fee := GetMarketFee(total)
+	refund := (trade.BuyOrder.Price - trade.Price) * int64(trade.Amount)
+
+	// The orders are only updated once the transaction has succeeded, so
+	// that they still match what is stored if it fails
+	buyOrder := proto.Clone(trade.BuyOrder).(*MarketOrder)
+	buyOrder.Amount = buyOrder.Amount - trade.Amount
+	sellOrder := proto.Clone(trade.SellOrder).(*MarketOrder)
+	sellOrder.Amount = sellOrder.Amount - trade.Amount
+
+	err := withGameStates(ctx, s.r, []string{buyerId, sellerId}, func(gss map[string]*GameState) error {
+		_, err := s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			err := setMarketResources(ctx, pipe, buyerId, gss[buyerId], refund, int64(trade.Amount))
+			if err != nil {
+				return err
+			}
+			err = setMarketResources(ctx, pipe, sellerId, gss[sellerId], total-fee, 0)
+			if err != nil {
+				return err
+			}
+			if err = updateMarketOrder(ctx, pipe, buyOrder); err != nil {
+				return err
+			}
+			return updateMarketOrder(ctx, pipe, sellOrder)
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to settle trade: %w", err)
+	}
+	trade.BuyOrder.Amount = buyOrder.Amount
+	trade.SellOrder.Amount = sellOrder.Amount
+
+	now := time.Now().UnixNano()
+	err = SaveReport(ctx, s.r, buyerId, &Report{
+		Id:        xid.New().String(),
+		CreatedAt: now,
+		Title:     "Pizzas bought",
+		Content: marketPrinter.Sprintf(
+			"We bought %d pizzas at the market for %d coins each, %d coins in total.",
+			trade.Amount, trade.Price, total),
+		Unread: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return SaveReport(ctx, s.r, sellerId, &Report{
+		Id:        xid.New().String(),
+		CreatedAt: now,
+		Title:     "Pizzas sold",
+		Content: marketPrinter.Sprintf(
+			"We sold %d pizzas at the market for %d coins each. After a market fee of %d coins, we earned %d coins.",
+			trade.Amount, trade.Price, fee, total-fee),
+		Unread: true,
+	})
+}
+
+// Removes the order from the market and gives back what is left in escrow
+func (s *MarketService) closeOrder(ctx context.Context, o *MarketOrder) error {
+	coins, pizzas := GetMarketEscrow(o.Side, o.Price, o.Amount)
+
+	return withGameStates(ctx, s.r, []string{o.UserId}, func(gss map[string]*GameState) error {
+		_, err := s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if err := setMarketResources(ctx, pipe, o.UserId, gss[o.UserId], coins, pizzas); err != nil {
+				return err
+			}
+			removeMarketOrder(ctx, pipe, o)
+			return nil
+		})
+		return err
+	})
+}
+
+// Cancels an open order of the user and gives back what is left in escrow
+func (s *MarketService) CancelOrder(ctx context.Context, userId string, orderId string) error {
+	unlock, err := s.lockMarket()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	orders, err := s.getOrdersByIds(ctx, []string{orderId})
+	if err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		return errors.New("market order not found")
+	}
+	if orders[0].UserId != userId {
+		return errors.New("can't cancel market order of another user")
+	}
+
+	if err = s.closeOrder(ctx, orders[0]); err != nil {
+		return fmt.Errorf("failed to cancel market order: %w", err)
+	}
+
+	return nil
+}
+
+// Closes all orders that have expired. The owners get back what is left in
+// escrow and a report about it. Returns the ids of the affected users.
+func (s *MarketService) ExpireOrders(ctx context.Context, now int64) ([]string, error) {
+	ids, err := s.r.ZRangeByScore(ctx, marketExpiryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", now),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read market expiry index: %w", err)
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	unlock, err := s.lockMarket()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Orders might have been filled or cancelled since the index was read
+	orders, err := s.getOrdersByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := []string{}
+	for _, o := range orders {
+		if err = s.closeOrder(ctx, o); err != nil {
+			return affected, fmt.Errorf("failed to expire market order: %w", err)
+		}
+		affected = append(affected, o.UserId)
+
+		content := marketPrinter.Sprintf(
+			"Our order to sell %d pizzas for %d coins each has expired. The pizzas have been returned to our town.",
+			o.Amount, o.Price)
+		if o.Side == MarketOrder_BUY {
+			content = marketPrinter.Sprintf(
+				"Our order to buy %d pizzas for %d coins each has expired. The coins have been returned to our town.",
+				o.Amount, o.Price)
+		}
+		err = SaveReport(ctx, s.r, o.UserId, &Report{
+			Id:        xid.New().String(),
+			CreatedAt: now,
+			Title:     "Market order expired",
+			Content:   content,
+			Unread:    true,
+		})
+		if err != nil {
+			return affected, err
+		}
+	}
+
+	return affected, nil
+}
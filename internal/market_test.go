@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchMarketOrder(t *testing.T) {
+	type trade struct {
+		BuyOrderId  string
+		SellOrderId string
+		Price       int64
+		Amount      int32
+	}
+
+	sells := []*MarketOrder{
+		{Id: "s1", UserId: "a", Side: MarketOrder_SELL, Price: 10, Amount: 5, ExpiresAt: 100},
+		{Id: "s2", UserId: "b", Side: MarketOrder_SELL, Price: 10, Amount: 5, ExpiresAt: 5},
+		{Id: "s3", UserId: "c", Side: MarketOrder_SELL, Price: 12, Amount: 10, ExpiresAt: 100},
+		{Id: "s4", UserId: "d", Side: MarketOrder_SELL, Price: 15, Amount: 10, ExpiresAt: 100},
+	}
+	buys := []*MarketOrder{
+		{Id: "b1", UserId: "a", Side: MarketOrder_BUY, Price: 12, Amount: 5, ExpiresAt: 100},
+		{Id: "b2", UserId: "b", Side: MarketOrder_BUY, Price: 11, Amount: 5, ExpiresAt: 100},
+	}
+
+	tests := map[string]struct {
+		order *MarketOrder
+		book  []*MarketOrder
+		want  []trade
+	}{
+		"empty book": {
+			order: &MarketOrder{Id: "x", UserId: "x", Side: MarketOrder_BUY, Price: 10, Amount: 5},
+			book:  []*MarketOrder{},
+			want:  []trade{},
+		},
+		"no matching price": {
+			order: &MarketOrder{Id: "x", UserId: "x", Side: MarketOrder_BUY, Price: 9, Amount: 5},
+			book:  sells,
+			want:  []trade{},
+		},
+		"buy at price of sell order": {
+			order: &MarketOrder{Id: "x", UserId: "x", Side: MarketOrder_BUY, Price: 11, Amount: 3},
+			book:  sells,
+			want:  []trade{{"x", "s1", 10, 3}},
+		},
+		"buy skips expired orders": {
+			order: &MarketOrder{Id: "x", UserId: "x", Side: MarketOrder_BUY, Price: 12, Amount: 8},
+			book:  sells,
+			want:  []trade{{"x", "s1", 10, 5}, {"x", "s3", 12, 3}},
+		},
+		"buy skips own orders": {
+			order: &MarketOrder{Id: "x", UserId: "a", Side: MarketOrder_BUY, Price: 20, Amount: 12},
+			book:  sells,
+			want:  []trade{{"x", "s3", 12, 10}, {"x", "s4", 15, 2}},
+		},
+		"sell at price of buy order": {
+			order: &MarketOrder{Id: "x", UserId: "x", Side: MarketOrder_SELL, Price: 11, Amount: 20},
+			book:  buys,
+			want:  []trade{{"b1", "x", 12, 5}, {"b2", "x", 11, 5}},
+		},
+		"sell with no matching price": {
+			order: &MarketOrder{Id: "x", UserId: "x", Side: MarketOrder_SELL, Price: 13, Amount: 20},
+			book:  buys,
+			want:  []trade{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := []trade{}
+			for _, tr := range MatchMarketOrder(test.order, test.book, 10) {
+				got = append(got, trade{tr.BuyOrder.Id, tr.SellOrder.Id, tr.Price, tr.Amount})
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("MatchMarketOrder(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetMarketFee(t *testing.T) {
+	tests := map[string]struct {
+		coins int64
+		want  int64
+	}{
+		"zero":       {coins: 0, want: 0},
+		"rounded up": {coins: 10, want: 1},
+		"exact":      {coins: 100, want: 5},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetMarketFee(test.coins)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetMarketFee(%d) mismatch (-want +got):\n%s", test.coins, diff)
+			}
+		})
+	}
+}
@@ -19,6 +19,10 @@ func (m *IncomingMessage) UnmarshalBinary(data []byte) error {
 type OutgoingMessage struct {
 	ReceiverId string `json:"receiver_id"`
 	Body     string `json:"body"`
+	// Broadcast messages are sent to all connected users, except for
+	// the excluded ones. The receiver id is ignored.
+	Broadcast  bool     `json:"broadcast,omitempty"`
+	ExcludeIds []string `json:"exclude_ids,omitempty"`
 }
 
 func (m *OutgoingMessage) MarshalBinary() (data []byte, err error) {
@@ -14,9 +14,10 @@ func (gs *GameState) ToServerMessage() *ServerMessage {
 	lotsPatch := map[string]*GameStatePatch_LotPatch{}
 	for lotId, lot := range gs.Lots {
 		lotsPatch[lotId] = &GameStatePatch_LotPatch{
-			Building: lot.Building,
-			TappedAt: lot.TappedAt,
-			Level:    lot.Level,
+			Building:      lot.Building,
+			TappedAt:      lot.TappedAt,
+			Level:         lot.Level,
+			DisabledUntil: lot.DisabledUntil,
 		}
 	}
 
@@ -29,6 +30,8 @@ func (gs *GameState) ToServerMessage() *ServerMessage {
 		pop.Guards = &wrapperspb.Int32Value{Value: gs.Population.Guards}
 		pop.Thieves = &wrapperspb.Int32Value{Value: gs.Population.Thieves}
 		pop.Publicists = &wrapperspb.Int32Value{Value: gs.Population.Publicists}
+		pop.Scouts = &wrapperspb.Int32Value{Value: gs.Population.Scouts}
+		pop.Saboteurs = &wrapperspb.Int32Value{Value: gs.Population.Saboteurs}
 	}
 
 	p := &GameStatePatch{
@@ -50,6 +53,13 @@ func (gs *GameState) ToServerMessage() *ServerMessage {
 		Discoveries:              gs.Discoveries,
 		ResearchQueuePatched:     true,
 		ResearchQueue:            gs.ResearchQueue,
+		StationedGuards:          gs.StationedGuards,
+		Reinforcements:           gs.Reinforcements,
+		IncomingTravels:          gs.IncomingTravels,
+		ProtectedUntil:           &wrapperspb.Int64Value{Value: gs.ProtectedUntil},
+		Injuries:                 gs.Injuries,
+		ThiefExperience:          &wrapperspb.Int32Value{Value: gs.ThiefExperience},
+		GuardExperience:          &wrapperspb.Int32Value{Value: gs.GuardExperience},
 	}
 
 	return &ServerMessage{
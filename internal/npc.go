@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
+	"google.golang.org/protobuf/proto"
+)
+
+// User ids of towns that are not controlled by players start with this
+const NpcIdPrefix = "npc-"
+
+// Share of the NPC towns that are bandit towns, the rest are merchant towns
+const NpcBanditShare = 0.4
+
+// Bandits raid a nearby player town once every NpcRaidInterval on average
+const NpcRaidInterval = 6 * time.Hour
+
+// How far away bandits look for towns to raid
+const NpcRaidRange = 12
+
+// Bandits need at least this many thieves at home to go raiding, and send
+// half of them
+const NpcRaidMinThieves = 4
+
+var npcMerchantNames = []string{
+	"Cheese Merchants",
+	"Olive Traders",
+	"Flour Guild",
+	"Spice Caravan",
+}
+
+var npcBanditNames = []string{
+	"Crust Bandits",
+	"Sewer Gang",
+	"Alley Rats",
+	"Moonlight Raiders",
+}
+
+// Returns true if the user id belongs to a town that is not controlled by
+// a player
+func IsNpc(userId string) bool {
+	return strings.HasPrefix(userId, NpcIdPrefix)
+}
+
+// Returns the population that the NPC town trains towards
+func GetNpcPopulation(kind Npc_Kind) *GameState_Population {
+	if kind == Npc_BANDIT {
+		return &GameState_Population{
+			Chefs:     7,
+			Salesmice: 5,
+			Guards:    10,
+			Thieves:   12,
+		}
+	}
+	return &GameState_Population{
+		Chefs:     12,
+		Salesmice: 9,
+		Guards:    5,
+	}
+}
+
+// Returns the game state of a new NPC town
+func NewNpcGameState(kind Npc_Kind) *GameState {
+	var level int32 = 1
+	var coins int32 = 20_000
+	if kind == Npc_BANDIT {
+		level = 0
+		coins = 5_000
+	}
+
+	return &GameState{
+		Resources: &GameState_Resources{
+			Coins:  coins,
+			Pizzas: 2_000,
+		},
+		Lots: map[string]*GameState_Lot{
+			"1": {Building: Building_KITCHEN, Level: level},
+			"2": {Building: Building_SHOP, Level: level},
+			"3": {Building: Building_HOUSE, Level: 1},
+			"4": {Building: Building_HOUSE, Level: 1},
+			"5": {Building: Building_SCHOOL, Level: 0},
+		},
+		Population:  GetNpcPopulation(kind),
+		Discoveries: []ResearchDiscovery{},
+		Timestamp:   time.Now().Unix(),
+		Npc:         &Npc{Kind: kind},
+	}
+}
+
+// Returns what the NPC town should train next to get back to its
+// population, or false if it lacks nothing it can afford
+func GetNpcTraining(gs *GameState) (Education, int32, bool) {
+	if gs.Npc == nil || gs.Population == nil || gs.Resources == nil {
+		return 0, 0, false
+	}
+
+	// Trainings that are already queued count as trained
+	pop := proto.Clone(gs.Population).(*GameState_Population)
+	for _, t := range gs.TrainingQueue {
+		switch t.Education {
+		case Education_CHEF:
+			pop.Chefs = pop.Chefs + t.Amount
+		case Education_SALESMOUSE:
+			pop.Salesmice = pop.Salesmice + t.Amount
+		case Education_GUARD:
+			pop.Guards = pop.Guards + t.Amount
+		case Education_THIEF:
+			pop.Thieves = pop.Thieves + t.Amount
+		}
+	}
+
+	// Injured guards and thieves out raiding will be back
+	pop.Guards = pop.Guards + CountInjuredGuards(gs)
+	pop.Thieves = pop.Thieves + CountTravellingPopulation(gs.TravelQueue)
+
+	want := GetNpcPopulation(gs.Npc.Kind)
+	needs := []struct {
+		edu     Education
+		missing int32
+	}{
+		{Education_GUARD, want.Guards - pop.Guards},
+		{Education_THIEF, want.Thieves - pop.Thieves},
+		{Education_CHEF, want.Chefs - pop.Chefs},
+		{Education_SALESMOUSE, want.Salesmice - pop.Salesmice},
+	}
+
+	for _, need := range needs {
+		amount := MinInt32(need.missing, gs.Population.Uneducated)
+		if cost := FullGameData.Educations[int32(need.edu)].Cost; cost > 0 {
+			amount = MinInt32(amount, gs.Resources.Coins/cost)
+		}
+		if amount > 0 {
+			return need.edu, amount, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// Places an NPC town in every zone that does not have one. The towns are
+// placed on free entries picked from the world seed.
+func (s *WorldService) PlaceNpcTowns(ctx context.Context) error {
+	worldSeed, err := GetWorldSeed(ctx, s.r)
+	if err != nil {
+		return err
+	}
+	terrain, err := s.GetTerrain(ctx)
+	if err != nil {
+		return err
+	}
+
+	for zx := 0; zx < terrain.size.ZonesX(); zx++ {
+		for zy := 0; zy < terrain.size.ZonesY(); zy++ {
+			zidx := getZoneIdxFromZoneXY(zx, zy)
+			zone, err := s.GetZoneIdx(ctx, zidx)
+			if err != nil {
+				return err
+			}
+			if zone == nil || countNpcTowns(zone) > 0 {
+				continue
+			}
+
+			free := []int{}
+			for eidx, e := range zone.Entries {
+				x, y := getEntryXY(zidx, eidx)
+				if e.GetTown() == nil && terrain.CanPlaceTown(x, y) {
+					free = append(free, eidx)
+				}
+			}
+			if len(free) == 0 {
+				continue
+			}
+
+			rnd := rand.New(rand.NewSource(DeriveSeed(worldSeed, "npc", strconv.Itoa(zidx))))
+			eidx := free[rnd.Intn(len(free))]
+			x, y := getEntryXY(zidx, eidx)
+
+			kind := Npc_MERCHANT
+			names := npcMerchantNames
+			if rnd.Float64() < NpcBanditShare {
+				kind = Npc_BANDIT
+				names = npcBanditNames
+			}
+
+			err = s.createNpcTown(ctx, x, y, kind, names[rnd.Intn(len(names))])
+			if err != nil {
+				return fmt.Errorf("failed to create npc town: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *WorldService) createNpcTown(ctx context.Context, x, y int, kind Npc_Kind, username string) error {
+	userId := NpcIdPrefix + xid.New().String()
+
+	gs := NewNpcGameState(kind)
+	gs.TownX = int32(x)
+	gs.TownY = int32(y)
+	b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(gs)
+	if err != nil {
+		return err
+	}
+	err = s.r.JsonSet(ctx, fmt.Sprintf("user:%s:gamestate", userId), ".", string(b)).Err()
+	if err != nil {
+		return err
+	}
+	err = s.r.HSet(ctx, fmt.Sprintf("user:%s", userId), "username", username).Err()
+	if err != nil {
+		return err
+	}
+	if err = IndexUsername(ctx, s.r, userId, username).Err(); err != nil {
+		return err
+	}
+
+	err = s.setEntryXY(ctx, x, y, &WorldEntry{
+		Object: &WorldEntry_Town_{
+			Town: &WorldEntry_Town{
+				UserId: userId,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err = IndexTown(ctx, s.r, userId, x, y).Err(); err != nil {
+		return err
+	}
+
+	// NPC towns are updated by the updater like the towns of players
+	if _, err = SetNextUpdate(s.r, ctx, userId, gs); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("userId", userId).
+		Str("kind", kind.String()).
+		Int("x", x).
+		Int("y", y).
+		Msg("Placed npc town")
+
+	return nil
+}
+
+func countNpcTowns(z *WorldZone) int {
+	count := 0
+	for _, e := range z.Entries {
+		if town := e.GetTown(); town != nil && IsNpc(town.UserId) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewNpcGameState(t *testing.T) {
+	for _, kind := range []Npc_Kind{Npc_MERCHANT, Npc_BANDIT} {
+		t.Run(kind.String(), func(t *testing.T) {
+			gs := NewNpcGameState(kind)
+			if pop, beds := CountAllPopulation(gs), CountMaxPopulation(gs); pop > beds {
+				t.Errorf("NewNpcGameState() population %d does not fit in %d beds", pop, beds)
+			}
+			if _, _, ok := GetNpcTraining(gs); ok {
+				t.Errorf("NewNpcGameState() wants to train")
+			}
+		})
+	}
+}
+
+func TestGetNpcTraining(t *testing.T) {
+	tests := map[string]struct {
+		population *GameState_Population
+		coins      int32
+		queue      []*Training
+		wantEdu    Education
+		wantAmount int32
+		wantOk     bool
+	}{
+		"guards first": {
+			population: &GameState_Population{Uneducated: 10, Chefs: 6, Salesmice: 5, Guards: 8, Thieves: 12},
+			coins:      100_000,
+			wantEdu:    Education_GUARD,
+			wantAmount: 2,
+			wantOk:     true,
+		},
+		"limited by coins": {
+			population: &GameState_Population{Uneducated: 10, Chefs: 7, Salesmice: 5, Guards: 10, Thieves: 6},
+			coins:      50_000,
+			wantEdu:    Education_THIEF,
+			wantAmount: 2,
+			wantOk:     true,
+		},
+		"free chefs when poor": {
+			population: &GameState_Population{Uneducated: 10, Chefs: 4, Salesmice: 5, Guards: 8, Thieves: 12},
+			coins:      0,
+			wantEdu:    Education_CHEF,
+			wantAmount: 3,
+			wantOk:     true,
+		},
+		"queued trainings count": {
+			population: &GameState_Population{Uneducated: 10, Chefs: 7, Salesmice: 5, Guards: 8, Thieves: 12},
+			coins:      100_000,
+			queue:      []*Training{{Education: Education_GUARD, Amount: 2}},
+			wantOk:     false,
+		},
+		"no uneducated": {
+			population: &GameState_Population{Chefs: 7, Salesmice: 5, Guards: 0, Thieves: 12},
+			coins:      100_000,
+			wantOk:     false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gs := &GameState{
+				Npc:           &Npc{Kind: Npc_BANDIT},
+				Population:    test.population,
+				Resources:     &GameState_Resources{Coins: test.coins},
+				TrainingQueue: test.queue,
+			}
+			edu, amount, ok := GetNpcTraining(gs)
+			if diff := cmp.Diff(test.wantOk, ok); diff != "" {
+				t.Fatalf("GetNpcTraining() ok mismatch (-want +got):\n%s", diff)
+			}
+			if !ok {
+				return
+			}
+			if diff := cmp.Diff(test.wantEdu, edu); diff != "" {
+				t.Errorf("GetNpcTraining() education mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantAmount, amount); diff != "" {
+				t.Errorf("GetNpcTraining() amount mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsNpc(t *testing.T) {
+	if !IsNpc(NpcIdPrefix + "abc") {
+		t.Errorf("IsNpc() = false for npc id")
+	}
+	if IsNpc("c0ffee") {
+		t.Errorf("IsNpc() = true for user id")
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// New towns are protected from heists for a while, so that new players
+// get a chance to get started. The protection ends early if the town grows
+// past the thresholds, or if the player steals from someone else. The
+// values can be overridden with the BEGINNER_PROTECTION_* env vars.
+var BeginnerProtectionDuration = envDurationOrDefault("BEGINNER_PROTECTION_DURATION", 72*time.Hour)
+var BeginnerProtectionMaxCoins = envInt32OrDefault("BEGINNER_PROTECTION_MAX_COINS", 100_000)
+var BeginnerProtectionMaxBuildings = int(envInt32OrDefault("BEGINNER_PROTECTION_MAX_BUILDINGS", 8))
+
+// After a successful heist the thieves of the same user have to wait this
+// long before they can steal from the same town again
+const StealCooldown = 2 * time.Hour
+
+var ErrTargetProtected = errors.New("target is under beginner protection")
+var ErrStealCooldown = errors.New("target was robbed recently")
+
+// Returns true if the town has grown past the beginner protection thresholds
+func HasOutgrownBeginnerProtection(gs *GameState) bool {
+	if gs.Resources != nil && gs.Resources.Coins >= BeginnerProtectionMaxCoins {
+		return true
+	}
+	return len(gs.Lots) >= BeginnerProtectionMaxBuildings
+}
+
+// Returns true if the town can not be robbed at the time now because of
+// beginner protection
+func IsBeginnerProtected(gs *GameState, now int64) bool {
+	return gs.ProtectedUntil > now && !HasOutgrownBeginnerProtection(gs)
+}
+
+func getStealCooldownKey(thiefId string, targetId string) string {
+	return fmt.Sprintf("user:%s:stealCooldown:%s", thiefId, targetId)
+}
+
+// Returns an error if the thieves of the user are not allowed to steal from
+// the target town
+func CheckCanSteal(ctx context.Context, r redis.Cmdable, thiefId string, targetId string, gsTarget *GameState) error {
+	if IsBeginnerProtected(gsTarget, time.Now().UnixNano()) {
+		return ErrTargetProtected
+	}
+
+	n, err := r.Exists(ctx, getStealCooldownKey(thiefId, targetId)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check steal cooldown: %w", err)
+	}
+	if n > 0 {
+		return ErrStealCooldown
+	}
+
+	return nil
+}
+
+func SetStealCooldown(ctx context.Context, r redis.Cmdable, thiefId string, targetId string) error {
+	err := r.Set(ctx, getStealCooldownKey(thiefId, targetId), 1, StealCooldown).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set steal cooldown: %w", err)
+	}
+	return nil
+}
+
+func envDurationOrDefault(key string, defaultVal time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Invalid duration, using default")
+		return defaultVal
+	}
+	return d
+}
+
+func envInt32OrDefault(key string, defaultVal int32) int32 {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	i, err := strconv.ParseInt(val, 10, 32)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Invalid integer, using default")
+		return defaultVal
+	}
+	return int32(i)
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsBeginnerProtected(t *testing.T) {
+	lots := func(n int) map[string]*GameState_Lot {
+		m := map[string]*GameState_Lot{}
+		for i := 0; i < n; i++ {
+			m[string(rune('a'+i))] = &GameState_Lot{}
+		}
+		return m
+	}
+
+	tests := map[string]struct {
+		gs   *GameState
+		want bool
+	}{
+		"never protected": {
+			gs:   &GameState{Resources: &GameState_Resources{}},
+			want: false,
+		},
+		"protected": {
+			gs: &GameState{
+				ProtectedUntil: 200,
+				Resources:      &GameState_Resources{Coins: 1_000},
+				Lots:           lots(2),
+			},
+			want: true,
+		},
+		"protection ran out": {
+			gs: &GameState{
+				ProtectedUntil: 100,
+				Resources:      &GameState_Resources{},
+			},
+			want: false,
+		},
+		"too many coins": {
+			gs: &GameState{
+				ProtectedUntil: 200,
+				Resources:      &GameState_Resources{Coins: BeginnerProtectionMaxCoins},
+			},
+			want: false,
+		},
+		"too many buildings": {
+			gs: &GameState{
+				ProtectedUntil: 200,
+				Resources:      &GameState_Resources{},
+				Lots:           lots(BeginnerProtectionMaxBuildings),
+			},
+			want: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := IsBeginnerProtected(test.gs, 150)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("IsBeginnerProtected() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnvOrDefault(t *testing.T) {
+	const key = "PIZZA_TRIBES_TEST_ENV"
+
+	tests := map[string]struct {
+		val          *string
+		wantDuration time.Duration
+		wantInt32    int32
+	}{
+		"unset": {
+			wantDuration: time.Hour,
+			wantInt32:    8,
+		},
+		"invalid": {
+			val:          proto.String("many"),
+			wantDuration: time.Hour,
+			wantInt32:    8,
+		},
+		"duration": {
+			val:          proto.String("30m"),
+			wantDuration: 30 * time.Minute,
+			wantInt32:    8,
+		},
+		"integer": {
+			val:          proto.String("12"),
+			wantDuration: time.Hour,
+			wantInt32:    12,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.val != nil {
+				os.Setenv(key, *test.val)
+				defer os.Unsetenv(key)
+			}
+			if diff := cmp.Diff(test.wantDuration, envDurationOrDefault(key, time.Hour)); diff != "" {
+				t.Errorf("envDurationOrDefault() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantInt32, envInt32OrDefault(key, 8)); diff != "" {
+				t.Errorf("envInt32OrDefault() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -58,6 +59,19 @@ func RedisJsonGet(c RedisProcesser, ctx context.Context, key string, path string
 	return cmd
 }
 
+// Gets the value at the path of every key. Keys that do not exist give nil.
+func RedisJsonMGet(c RedisProcesser, ctx context.Context, path string, keys ...string) *redis.SliceCmd {
+	args := make([]interface{}, 0, len(keys)+2)
+	args = append(args, "JSON.MGET")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, path)
+	cmd := redis.NewSliceCmd(ctx, args...)
+	_ = c.Process(ctx, cmd)
+	return cmd
+}
+
 func RedisJsonNumIncrBy(c RedisProcesser, ctx context.Context, key string, path string, value int64) *redis.StringCmd {
 	cmd := redis.NewStringCmd(ctx, "JSON.NUMINCRBY", key, path, value)
 	_ = c.Process(ctx, cmd)
@@ -70,6 +84,25 @@ func RedisJsonSet(c RedisProcesser, ctx context.Context, key string, path string
 	return cmd
 }
 
+// Sets the value at the path only if it does not already exist. The result
+// is redis.Nil if nothing was written.
+func RedisJsonSetNX(c RedisProcesser, ctx context.Context, key string, path string, value interface{}) *redis.StatusCmd {
+	cmd := redis.NewStatusCmd(ctx, "JSON.SET", key, path, value, "NX")
+	_ = c.Process(ctx, cmd)
+	return cmd
+}
+
+// Creates an empty object at the path unless something already exists
+// there. Maps are left out of the game state when they are empty, and
+// entries can not be written to them until they exist.
+func RedisJsonEnsureObject(c RedisProcesser, ctx context.Context, key string, path string) error {
+	err := RedisJsonSetNX(c, ctx, key, path, "{}").Err()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return nil
+}
+
 func RedisJsonDel(c RedisProcesser, ctx context.Context, key string, path string) *redis.IntCmd {
 	cmd := redis.NewIntCmd(ctx, "JSON.DEL", key, path)
 	_ = c.Process(ctx, cmd)
@@ -82,6 +115,12 @@ func RedisJsonArrAppend(c RedisProcesser, ctx context.Context, key string, path
 	return cmd
 }
 
+func RedisJsonArrInsert(c RedisProcesser, ctx context.Context, key string, path string, index int, value interface{}) *redis.StatusCmd {
+	cmd := redis.NewStatusCmd(ctx, "JSON.ARRINSERT", key, path, index, value)
+	_ = c.Process(ctx, cmd)
+	return cmd
+}
+
 func RedisJsonArrTrim(c RedisProcesser, ctx context.Context, key string, path string, start int, end int) *redis.StatusCmd {
 	cmd := redis.NewStatusCmd(ctx, "JSON.ARRTRIM", key, path, start, end)
 	_ = c.Process(ctx, cmd)
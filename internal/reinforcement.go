@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+)
+
+// The owner pays a coin for every guard stationed in another town each
+// time this many seconds have passed
+const StationedGuardUpkeepInterval = 60
+
+// Coins paid by the owner for every guard stationed in another town
+const StationedGuardUpkeepPerSecond = 1.0 / StationedGuardUpkeepInterval
+
+// Returns the coins that the user pays for the guards stationed in other
+// towns between the timestamps (unix seconds). The guards are paid a whole
+// coin every time an interval starts, so that no upkeep is lost to
+// rounding however often the game state is updated.
+func CalculateUpkeep(gs *GameState, from int64, to int64) int32 {
+	intervals := to/StationedGuardUpkeepInterval - from/StationedGuardUpkeepInterval
+	if intervals <= 0 {
+		return 0
+	}
+	return CountStationedGuards(gs) * int32(intervals)
+}
+
+// Returns the number of guards that the user has stationed in other towns
+func CountStationedGuards(gs *GameState) int32 {
+	var count int32 = 0
+	for _, r := range gs.StationedGuards {
+		count = count + r.Guards
+	}
+	return count
+}
+
+// Returns the number of guards from other users that help defending the
+// town of the user
+func CountReinforcingGuards(gs *GameState) int32 {
+	var count int32 = 0
+	for _, r := range gs.Reinforcements {
+		count = count + r.Guards
+	}
+	return count
+}
+
+// Returns the number of guards that defend the town, including guards
+// stationed in the town by other users
+func CountDefendingGuards(gs *GameState) int32 {
+	var guards int32 = 0
+	if gs.Population != nil {
+		guards = gs.Population.Guards
+	}
+	return guards + CountReinforcingGuards(gs)
+}
+
+// Writes the reinforcement to the map at the path, or removes it from the
+// map if it has no guards left.
+func SaveReinforcement(ctx context.Context, pipe redis.Pipeliner, gsKey string, path string, userId string, reinforcement *Reinforcement) error {
+	entryPath := fmt.Sprintf("%s[\"%s\"]", path, userId)
+
+	if reinforcement.Guards <= 0 {
+		if err := RedisJsonDel(pipe, ctx, gsKey, entryPath).Err(); err != nil {
+			return fmt.Errorf("failed to remove reinforcement: %w", err)
+		}
+		return nil
+	}
+
+	b, err := protojson.Marshal(reinforcement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reinforcement: %w", err)
+	}
+	if err = RedisJsonSet(pipe, ctx, gsKey, entryPath, b).Err(); err != nil {
+		return fmt.Errorf("failed to write reinforcement: %w", err)
+	}
+
+	return nil
+}
+
+// Sends the guards that the owner has stationed in the town of the host
+// back home. Both game states are locked, so that the host can not be
+// updated while its reinforcements are removed. Returns the number of
+// guards that were recalled.
+func RecallReinforcements(ctx context.Context, r RedisClient, world *WorldService, ownerId string, hostId string) (int32, error) {
+	var recalled int32
+
+	err := withGameStates(ctx, r, []string{ownerId, hostId}, func(gss map[string]*GameState) error {
+		gs := gss[ownerId]
+		stationed := gs.StationedGuards[hostId]
+		if stationed == nil || stationed.Guards <= 0 {
+			return nil
+		}
+
+		arrivalAt, err := world.CalculateArrivalTime(ctx,
+			stationed.TownX, stationed.TownY,
+			gs.TownX, gs.TownY,
+			GuardSpeed)
+		if err != nil {
+			return err
+		}
+
+		travel := &Travel{
+			ArrivalAt:    arrivalAt,
+			DestinationX: stationed.TownX,
+			DestinationY: stationed.TownY,
+			Returning:    true,
+			Guards:       stationed.Guards,
+		}
+
+		gsKey := fmt.Sprintf("user:%s:gamestate", ownerId)
+		gsKeyHost := fmt.Sprintf("user:%s:gamestate", hostId)
+		_, err = r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			removed := &Reinforcement{}
+			err := SaveReinforcement(ctx, pipe, gsKey, ".stationedGuards", hostId, removed)
+			if err != nil {
+				return err
+			}
+			err = SaveReinforcement(ctx, pipe, gsKeyHost, ".reinforcements", ownerId, removed)
+			if err != nil {
+				return err
+			}
+			return InsertTravel(ctx, pipe, gsKey, gs.TravelQueue, travel)
+		})
+		if err != nil {
+			return err
+		}
+
+		// The owner has to be updated when the guards are back
+		gs.TravelQueue = append(gs.TravelQueue, travel)
+		if _, err = SetNextUpdate(r, ctx, ownerId, gs); err != nil {
+			return err
+		}
+
+		recalled = travel.Guards
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to recall reinforcements: %w", err)
+	}
+
+	return recalled, nil
+}
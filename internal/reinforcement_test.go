@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCountDefendingGuards(t *testing.T) {
+	tests := map[string]struct {
+		gs   *GameState
+		want int32
+	}{
+		"no population": {
+			gs:   &GameState{},
+			want: 0,
+		},
+		"own guards": {
+			gs: &GameState{
+				Population: &GameState_Population{Guards: 5},
+			},
+			want: 5,
+		},
+		"reinforced": {
+			gs: &GameState{
+				Population: &GameState_Population{Guards: 5},
+				Reinforcements: map[string]*Reinforcement{
+					"a": {UserId: "a", Guards: 3},
+					"b": {UserId: "b", Guards: 7},
+				},
+			},
+			want: 15,
+		},
+		"stationed elsewhere": {
+			gs: &GameState{
+				Population: &GameState_Population{Guards: 5},
+				StationedGuards: map[string]*Reinforcement{
+					"a": {UserId: "a", Guards: 3},
+				},
+			},
+			want: 5,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CountDefendingGuards(test.gs)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CountDefendingGuards() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCountAllPopulationWithGuardsAway(t *testing.T) {
+	gs := &GameState{
+		Population: &GameState_Population{Guards: 5},
+		TravelQueue: []*Travel{
+			{Guards: 2},
+			{Guards: 1, Returning: true},
+		},
+		StationedGuards: map[string]*Reinforcement{
+			"a": {UserId: "a", Guards: 3},
+		},
+		Reinforcements: map[string]*Reinforcement{
+			"b": {UserId: "b", Guards: 10},
+		},
+	}
+
+	got := CountAllPopulation(gs)
+	if diff := cmp.Diff(int32(11), got); diff != "" {
+		t.Errorf("CountAllPopulation() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCalculateUpkeep(t *testing.T) {
+	oneGuard := &GameState{
+		StationedGuards: map[string]*Reinforcement{"a": {Guards: 1}},
+	}
+	guards := &GameState{
+		StationedGuards: map[string]*Reinforcement{
+			"a": {Guards: 3},
+			"b": {Guards: 2},
+		},
+	}
+
+	tests := map[string]struct {
+		gs   *GameState
+		from int64
+		to   int64
+		want int32
+	}{
+		"no stationed guards": {gs: &GameState{}, from: 0, to: 600, want: 0},
+		"one guard a minute":  {gs: oneGuard, from: 0, to: 60, want: 1},
+		"across an interval":  {gs: oneGuard, from: 30, to: 90, want: 1},
+		"within an interval":  {gs: oneGuard, from: 10, to: 50, want: 0},
+		"many guards":         {gs: guards, from: 120, to: 300, want: 15},
+		"backwards":           {gs: guards, from: 300, to: 100, want: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CalculateUpkeep(test.gs, test.from, test.to)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CalculateUpkeep() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCalculateUpkeepFrequentUpdates(t *testing.T) {
+	// One stationed guard costs a coin over a minute, even when the game
+	// state is updated every 10 seconds
+	gs := &GameState{
+		StationedGuards: map[string]*Reinforcement{"a": {Guards: 1}},
+	}
+
+	var total int32 = 0
+	for ts := int64(1_000_005); ts < 1_000_065; ts += 10 {
+		total = total + CalculateUpkeep(gs, ts, ts+10)
+	}
+	if diff := cmp.Diff(int32(1), total); diff != "" {
+		t.Errorf("CalculateUpkeep() total mismatch (-want +got):\n%s", diff)
+	}
+}
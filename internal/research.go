@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+)
+
+// Every town can always research one discovery at a time.
+const BaseResearchSlots = 1
+
+// Returns the number of research slots and the research speed multiplier
+// of the town. Research institutes do not stack, only the best one counts.
+func CountResearchSlots(gs *GameState) (slots int32, speed float64) {
+	slots = BaseResearchSlots
+	speed = 1.0
+	now := time.Now().UnixNano()
+	for _, lot := range gs.Lots {
+		info := FullGameData.Buildings[int32(lot.Building)]
+		if info == nil || int(lot.Level) >= len(info.LevelInfos) || IsLotDisabled(lot, now) {
+			continue
+		}
+		if r := info.LevelInfos[lot.Level].Researcher; r != nil {
+			if r.Slots > slots {
+				slots = r.Slots
+			}
+			if r.Speed > speed {
+				speed = r.Speed
+			}
+		}
+	}
+
+	return slots, speed
+}
+
+// Returns the time (unix nano) when a new research can be started
+// in the earliest free research slot.
+func GetResearchStartTime(queue []*OngoingResearch, slots int32, now int64) int64 {
+	completeAts := make([]int64, len(queue))
+	for i, r := range queue {
+		completeAts[i] = r.CompleteAt
+	}
+
+	return getSlotStartTime(completeAts, slots, now)
+}
+
+// Splits the research queue into the researchs that have been completed
+// and the ones that are still ongoing. Since research can run in parallel
+// slots, the queue is not assumed to be sorted.
+func SplitCompletedResearchs(queue []*OngoingResearch, now int64) (completed, ongoing []*OngoingResearch) {
+	ongoing = []*OngoingResearch{}
+	for _, r := range queue {
+		if r.CompleteAt > now {
+			ongoing = append(ongoing, r)
+		} else {
+			completed = append(completed, r)
+		}
+	}
+
+	return completed, ongoing
+}
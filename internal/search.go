@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+)
+
+// Lowercased usernames followed by the user id, all with the same score so
+// that they can be looked up by prefix
+const usernameIndexKey = "index:usernames"
+
+// User ids of towns scored by their position, see getTownScore
+const townIndexKey = "index:towns"
+
+// The row stride of town scores. It is larger than the world can grow, so
+// that the score of a town never changes.
+const townIndexStride = 1 << 16
+
+const TownSearchMaxResults = 20
+const TownSearchMaxRadius = 30
+
+func getTownScore(x, y int) int {
+	return y*townIndexStride + x
+}
+
+func getTownXY(score float64) (x, y int) {
+	s := int(score)
+	return s % townIndexStride, s / townIndexStride
+}
+
+func getUsernameIndexMember(userId, username string) string {
+	return strings.ToLower(username) + "\x00" + userId
+}
+
+// Adds the user to the index used to search for users by username
+func IndexUsername(ctx context.Context, r redis.Cmdable, userId, username string) *redis.IntCmd {
+	return r.ZAdd(ctx, usernameIndexKey, &redis.Z{
+		Score:  0,
+		Member: getUsernameIndexMember(userId, username),
+	})
+}
+
+// Adds the town to the index used to search for towns by position
+func IndexTown(ctx context.Context, r redis.Cmdable, userId string, x, y int) *redis.IntCmd {
+	return r.ZAdd(ctx, townIndexKey, &redis.Z{
+		Score:  float64(getTownScore(x, y)),
+		Member: userId,
+	})
+}
+
+// Sorts the towns by distance to x, y and then by user id
+func sortTownsByDistance(towns []*TownSearchResult_Town, x, y int) {
+	for _, t := range towns {
+		dx, dy := float64(int(t.X)-x), float64(int(t.Y)-y)
+		t.Distance = math.Sqrt(dx*dx + dy*dy)
+	}
+	sort.SliceStable(towns, func(i, j int) bool {
+		if towns[i].Distance != towns[j].Distance {
+			return towns[i].Distance < towns[j].Distance
+		}
+		return towns[i].UserId < towns[j].UserId
+	})
+}
+
+// Returns the towns within the distance of x, y, except the town at x, y,
+// sorted by distance
+func (s *WorldService) GetTownsInRange(ctx context.Context, x, y int, distance int) ([]TownLocation, error) {
+	towns, err := s.getTownsInRange(ctx, x, y, distance)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]TownLocation, len(towns))
+	for i, t := range towns {
+		locations[i] = TownLocation{UserId: t.UserId, X: int(t.X), Y: int(t.Y)}
+	}
+	return locations, nil
+}
+
+func (s *WorldService) getTownsInRange(ctx context.Context, x, y int, distance int) ([]*TownSearchResult_Town, error) {
+	// Each row within the distance is a single range of scores
+	cmds := []*redis.ZSliceCmd{}
+	_, err := s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for ty := y - distance; ty <= y+distance; ty++ {
+			if ty < 0 {
+				continue
+			}
+			minX := int(Max(int64(x-distance), 0))
+			cmds = append(cmds, pipe.ZRangeByScoreWithScores(ctx, townIndexKey, &redis.ZRangeBy{
+				Min: strconv.Itoa(getTownScore(minX, ty)),
+				Max: strconv.Itoa(getTownScore(x+distance, ty)),
+			}))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get towns in range: %w", err)
+	}
+
+	towns := []*TownSearchResult_Town{}
+	for _, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			tx, ty := getTownXY(z.Score)
+			dx, dy := tx-x, ty-y
+			if (dx == 0 && dy == 0) || dx*dx+dy*dy > distance*distance {
+				continue
+			}
+			towns = append(towns, &TownSearchResult_Town{
+				UserId: z.Member.(string),
+				X:      int32(tx),
+				Y:      int32(ty),
+			})
+		}
+	}
+	sortTownsByDistance(towns, x, y)
+
+	return towns, nil
+}
+
+// Returns the towns of the users whose username starts with the prefix,
+// sorted by distance to the town of the user
+func (s *WorldService) SearchTownsByUsername(ctx context.Context, userId, prefix string) (*TownSearchResult, error) {
+	x, y, err := s.getTownXY(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.ToLower(prefix)
+	members, err := s.r.ZRangeByLex(ctx, usernameIndexKey, &redis.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: TownSearchMaxResults,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search usernames: %w", err)
+	}
+
+	userIds := make([]string, 0, len(members))
+	for _, m := range members {
+		if i := strings.LastIndexByte(m, 0); i >= 0 {
+			userIds = append(userIds, m[i+1:])
+		}
+	}
+
+	cmds := make([]*redis.FloatCmd, len(userIds))
+	_, err = s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range userIds {
+			cmds[i] = pipe.ZScore(ctx, townIndexKey, id)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get towns: %w", err)
+	}
+
+	towns := []*TownSearchResult_Town{}
+	for i, cmd := range cmds {
+		score, err := cmd.Result()
+		if err != nil {
+			// The user has not got a town yet
+			continue
+		}
+		tx, ty := getTownXY(score)
+		towns = append(towns, &TownSearchResult_Town{
+			UserId: userIds[i],
+			X:      int32(tx),
+			Y:      int32(ty),
+		})
+	}
+	sortTownsByDistance(towns, x, y)
+
+	return s.withUsernames(ctx, towns)
+}
+
+// Returns the towns within the radius of the town of the user, sorted by
+// distance
+func (s *WorldService) SearchNearbyTowns(ctx context.Context, userId string, radius int) (*TownSearchResult, error) {
+	x, y, err := s.getTownXY(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	towns, err := s.getTownsInRange(ctx, x, y, radius)
+	if err != nil {
+		return nil, err
+	}
+	if len(towns) > TownSearchMaxResults {
+		towns = towns[:TownSearchMaxResults]
+	}
+
+	return s.withUsernames(ctx, towns)
+}
+
+func (s *WorldService) getTownXY(ctx context.Context, userId string) (x, y int, err error) {
+	str, err := s.r.JsonGet(ctx, fmt.Sprintf("user:%s:gamestate", userId), ".").Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get game state: %w", err)
+	}
+	gs := &GameState{}
+	if err = protojson.Unmarshal([]byte(str), gs); err != nil {
+		return 0, 0, fmt.Errorf("failed to unmarshal game state: %w", err)
+	}
+	return int(gs.TownX), int(gs.TownY), nil
+}
+
+func (s *WorldService) withUsernames(ctx context.Context, towns []*TownSearchResult_Town) (*TownSearchResult, error) {
+	cmds := make([]*redis.StringCmd, len(towns))
+	_, err := s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, t := range towns {
+			cmds[i] = pipe.HGet(ctx, fmt.Sprintf("user:%s", t.UserId), "username")
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get usernames: %w", err)
+	}
+	for i, cmd := range cmds {
+		towns[i].Username = cmd.Val()
+	}
+
+	return &TownSearchResult{Towns: towns}, nil
+}
+
+// Adds every user and town to the search indexes. Used to index the users
+// and towns that existed before the indexes.
+func (s *WorldService) RebuildSearchIndexes(ctx context.Context) error {
+	size, err := s.GetSize(ctx)
+	if err != nil {
+		return err
+	}
+
+	for zx := 0; zx < size.ZonesX(); zx++ {
+		for zy := 0; zy < size.ZonesY(); zy++ {
+			zidx := getZoneIdxFromZoneXY(zx, zy)
+			zone, err := s.GetZoneIdx(ctx, zidx)
+			if err != nil {
+				return err
+			}
+			if zone == nil {
+				continue
+			}
+
+			for eidx, e := range zone.Entries {
+				town := e.GetTown()
+				if town == nil {
+					continue
+				}
+				username, err := s.r.HGet(ctx, fmt.Sprintf("user:%s", town.UserId), "username").Result()
+				if err != nil {
+					return fmt.Errorf("failed to get username: %w", err)
+				}
+				x, y := getEntryXY(zidx, eidx)
+				_, err = s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+					IndexUsername(ctx, pipe, town.UserId, username)
+					IndexTown(ctx, pipe, town.UserId, x, y)
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("failed to index town: %w", err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestGetTownXY(t *testing.T) {
+	tests := map[string]xy{
+		"origin":      {0, 0},
+		"first row":   {109, 0},
+		"first col":   {0, 109},
+		"grown world": {WorldMaxSize - 1, WorldMaxSize - 1},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			x, y := getTownXY(float64(getTownScore(test.x, test.y)))
+			if diff := cmp.Diff(test, xy{x, y}, cmp.AllowUnexported(xy{})); diff != "" {
+				t.Errorf("getTownXY() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortTownsByDistance(t *testing.T) {
+	// Towns at the same distance are sorted by user id
+	towns := []*TownSearchResult_Town{
+		{UserId: "far", X: 10, Y: 10},
+		{UserId: "b", X: 2, Y: 1},
+		{UserId: "a", X: 8, Y: 1},
+		{UserId: "near", X: 5, Y: 2},
+	}
+
+	sortTownsByDistance(towns, 5, 0)
+
+	want := []*TownSearchResult_Town{
+		{UserId: "near", X: 5, Y: 2, Distance: 2},
+		{UserId: "a", X: 8, Y: 1, Distance: 3.1622776601683795},
+		{UserId: "b", X: 2, Y: 1, Distance: 3.1622776601683795},
+		{UserId: "far", X: 10, Y: 10, Distance: 11.180339887498949},
+	}
+	if diff := cmp.Diff(want, towns, protocmp.Transform()); diff != "" {
+		t.Errorf("sortTownsByDistance() mismatch (-want +got):\n%s", diff)
+	}
+}
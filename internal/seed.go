@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+const worldSeedKey = "world:seed"
+
+// Sets the seed of the world unless it already has one
+func EnsureWorldSeed(ctx context.Context, r redis.Cmdable, seed uint64) error {
+	if err := r.SetNX(ctx, worldSeedKey, seed, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set world seed: %w", err)
+	}
+	return nil
+}
+
+// Returns the seed of the world. A world without a seed gets a new one.
+func GetWorldSeed(ctx context.Context, r redis.Cmdable) (uint64, error) {
+	seed, err := r.Get(ctx, worldSeedKey).Uint64()
+	if err == redis.Nil {
+		if err = EnsureWorldSeed(ctx, r, uint64(time.Now().UnixNano())); err != nil {
+			return 0, err
+		}
+		seed, err = r.Get(ctx, worldSeedKey).Uint64()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get world seed: %w", err)
+	}
+	return seed, nil
+}
+
+// Derives a seed for a single event, such as a heist, from the seed of the
+// world. The same parts always give the same seed, so that the outcome of
+// the event can be reproduced.
+func DeriveSeed(seed uint64, parts ...string) uint64 {
+	h := fnv.New64a()
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, seed)
+	h.Write(b)
+	for _, part := range parts {
+		// Separate the parts so that ("ab", "c") and ("a", "bc") differ
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return h.Sum64()
+}
+
+// Returns the seed for the outcome of the travel at its destination
+func GetTravelSeed(seed uint64, kind string, travel *Travel) uint64 {
+	id := travel.Id
+	if id == "" {
+		// Travels sent before they had ids are told apart by their
+		// destination and arrival time instead
+		id = fmt.Sprintf("%d:%d:%d", travel.DestinationX, travel.DestinationY, travel.ArrivalAt)
+	}
+	return DeriveSeed(seed, kind, id)
+}
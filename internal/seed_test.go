@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeriveSeed(t *testing.T) {
+	tests := map[string]struct {
+		a    []string
+		b    []string
+		same bool
+	}{
+		"same parts":      {a: []string{"steal", "x"}, b: []string{"steal", "x"}, same: true},
+		"different kinds": {a: []string{"steal", "x"}, b: []string{"scout", "x"}, same: false},
+		"different ids":   {a: []string{"steal", "x"}, b: []string{"steal", "y"}, same: false},
+		"moved boundary":  {a: []string{"ab", "c"}, b: []string{"a", "bc"}, same: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := DeriveSeed(42, test.a...) == DeriveSeed(42, test.b...)
+			if diff := cmp.Diff(test.same, got); diff != "" {
+				t.Errorf("DeriveSeed(%v) == DeriveSeed(%v) mismatch (-want +got):\n%s", test.a, test.b, diff)
+			}
+		})
+	}
+
+	if DeriveSeed(1, "steal", "x") == DeriveSeed(2, "steal", "x") {
+		t.Errorf("DeriveSeed() gave the same seed for different worlds")
+	}
+}
+
+func TestGetTravelSeed(t *testing.T) {
+	a := GetTravelSeed(42, "steal", &Travel{DestinationX: 1, DestinationY: 2, ArrivalAt: 3})
+	b := GetTravelSeed(42, "steal", &Travel{DestinationX: 1, DestinationY: 2, ArrivalAt: 4})
+	if a == b {
+		t.Errorf("GetTravelSeed() gave the same seed for travels without ids")
+	}
+
+	c := GetTravelSeed(42, "steal", &Travel{Id: "x", ArrivalAt: 3})
+	d := GetTravelSeed(42, "steal", &Travel{Id: "x", ArrivalAt: 4})
+	if diff := cmp.Diff(c, d); diff != "" {
+		t.Errorf("GetTravelSeed() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -68,6 +68,9 @@ func calculateBakeBonus(gs *GameState) float64 {
 	if gs.HasDiscovery(ResearchDiscovery_HYBRID_OVEN) {
 		bonus = bonus + 0.1
 	}
+	if gs.HasDiscovery(ResearchDiscovery_SMART_OVEN) {
+		bonus = bonus + 0.15
+	}
 
 	return bonus
 }
@@ -101,5 +104,7 @@ func CalculateStats(gs *GameState) *Stats {
 		PizzasProducedPerSecond: pizzasProducedPerSecond,
 		DemandOffpeak:           demandOffpeak,
 		DemandRushHour:          demandRushHour,
+		UpkeepPerSecond:         float64(CountStationedGuards(gs)) * StationedGuardUpkeepPerSecond,
+		ProtectedCoins:          CountProtectedCoins(gs),
 	}
 }
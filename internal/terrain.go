@@ -0,0 +1,356 @@
+package internal
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrNoPath = errors.New("there is no path to the destination")
+
+// Roads run along every RoadSpacing row and column of the world
+const RoadSpacing = 22
+
+// How long it takes to cross each land type compared to grass
+var landTypeCosts = map[WorldEntry_LandType]float64{
+	WorldEntry_GRASS:    1,
+	WorldEntry_FOREST:   1.5,
+	WorldEntry_RIVER:    3,
+	WorldEntry_MOUNTAIN: math.Inf(1),
+	WorldEntry_ROAD:     0.5,
+}
+
+// The cheapest land type to cross, used to estimate the remaining cost
+// when searching for paths
+const minLandTypeCost = 0.5
+
+func IsPassable(landType WorldEntry_LandType) bool {
+	return !math.IsInf(landTypeCosts[landType], 1)
+}
+
+// The land types of every entry in the world. The terrain is generated
+// from the world seed, so every service can derive the same terrain
+// without reading it from Redis.
+type Terrain struct {
+	size      WorldSize
+	landTypes []WorldEntry_LandType
+
+	// The entries of the largest region of passable entries, found the
+	// first time it is needed
+	mainRegion     []bool
+	mainRegionOnce sync.Once
+}
+
+// Mixes the value into a pseudo random number
+func splitmix64(v uint64) uint64 {
+	v = v + 0x9e3779b97f4a7c15
+	v = (v ^ (v >> 30)) * 0xbf58476d1ce4e5b9
+	v = (v ^ (v >> 27)) * 0x94d049bb133111eb
+	return v ^ (v >> 31)
+}
+
+// Returns a pseudo random value in [0, 1) for the lattice point
+func latticeValue(seed uint64, i, j int) float64 {
+	v := splitmix64(seed ^ splitmix64(uint64(int64(i))*0x100000001b3^uint64(int64(j))))
+	return float64(v>>11) / (1 << 53)
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// Returns smooth noise in [0, 1) where features are about scale entries
+// wide
+func valueNoise(seed uint64, x, y int, scale float64) float64 {
+	fx := float64(x) / scale
+	fy := float64(y) / scale
+	i, j := int(math.Floor(fx)), int(math.Floor(fy))
+	tx, ty := smoothstep(fx-float64(i)), smoothstep(fy-float64(j))
+
+	a := latticeValue(seed, i, j)
+	b := latticeValue(seed, i+1, j)
+	c := latticeValue(seed, i, j+1)
+	d := latticeValue(seed, i+1, j+1)
+
+	top := a + (b-a)*tx
+	bottom := c + (d-c)*tx
+	return top + (bottom-top)*ty
+}
+
+// Generates the terrain of the world from the seed. The land type of an
+// entry only depends on the seed and its coordinates, so the terrain stays
+// the same when the world grows.
+func NewTerrain(seed uint64, size WorldSize) *Terrain {
+	elevationSeed := DeriveSeed(seed, "elevation")
+	moistureSeed := DeriveSeed(seed, "moisture")
+	riverSeed := DeriveSeed(seed, "river")
+
+	t := &Terrain{
+		size:      size,
+		landTypes: make([]WorldEntry_LandType, size.Width*size.Height),
+	}
+	for y := 0; y < size.Height; y++ {
+		for x := 0; x < size.Width; x++ {
+			elevation := 0.7*valueNoise(elevationSeed, x, y, 12) +
+				0.3*valueNoise(elevationSeed+1, x, y, 5)
+			moisture := valueNoise(moistureSeed, x, y, 10)
+			river := math.Abs(valueNoise(riverSeed, x, y, 16) - 0.5)
+
+			landType := WorldEntry_GRASS
+			switch {
+			case elevation > 0.72:
+				landType = WorldEntry_MOUNTAIN
+			case x%RoadSpacing == RoadSpacing/2 || y%RoadSpacing == RoadSpacing/2:
+				// Roads are bridged over rivers
+				landType = WorldEntry_ROAD
+			case river < 0.02:
+				landType = WorldEntry_RIVER
+			case moisture > 0.62:
+				landType = WorldEntry_FOREST
+			}
+			t.landTypes[y*size.Width+x] = landType
+		}
+	}
+
+	return t
+}
+
+func (t *Terrain) GetLandType(x, y int) WorldEntry_LandType {
+	if !t.size.Contains(x, y) {
+		return WorldEntry_MOUNTAIN
+	}
+	return t.landTypes[y*t.size.Width+x]
+}
+
+// Returns true if a town can be placed on the entry. Only entries in the
+// largest connected region of passable entries can have towns, so that
+// every town can be reached from every other town.
+func (t *Terrain) CanPlaceTown(x, y int) bool {
+	if !t.size.Contains(x, y) {
+		return false
+	}
+	t.mainRegionOnce.Do(t.findMainRegion)
+	return t.mainRegion[y*t.size.Width+x]
+}
+
+// Flood fills the passable entries to find the largest connected region
+func (t *Terrain) findMainRegion() {
+	width := t.size.Width
+
+	// The region of every entry, where 0 means that it has not been
+	// visited yet
+	regions := make([]int, len(t.landTypes))
+	sizes := []int{0}
+	for start := range t.landTypes {
+		if regions[start] != 0 || !IsPassable(t.landTypes[start]) {
+			continue
+		}
+		region := len(sizes)
+		sizes = append(sizes, 0)
+		regions[start] = region
+		stack := []int{start}
+		for len(stack) > 0 {
+			idx := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			sizes[region]++
+
+			x, y := idx%width, idx/width
+			for _, o := range xyOffsets {
+				nx, ny := x+o.x, y+o.y
+				if !t.size.Contains(nx, ny) {
+					continue
+				}
+				nidx := ny*width + nx
+				if regions[nidx] != 0 || !IsPassable(t.landTypes[nidx]) {
+					continue
+				}
+				regions[nidx] = region
+				stack = append(stack, nidx)
+			}
+		}
+	}
+
+	main := 0
+	for region, size := range sizes {
+		if size > sizes[main] {
+			main = region
+		}
+	}
+	t.mainRegion = make([]bool, len(regions))
+	for idx, region := range regions {
+		t.mainRegion[idx] = main != 0 && region == main
+	}
+}
+
+type pathNode struct {
+	idx      int
+	cost     float64
+	estimate float64
+}
+
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].estimate < q[j].estimate }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathNode)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// Returns the cost of the cheapest path between the entries, where every
+// step costs its length times the cost of the land type it enters. The
+// destination can be entered even if it is impassable, but every other
+// entry along the path has to be passable, so there is no path to or from
+// a town that is surrounded by mountains.
+func (t *Terrain) FindPathCost(fromX, fromY, toX, toY int) (float64, error) {
+	if fromX == toX && fromY == toY {
+		return 0, nil
+	}
+
+	width := t.size.Width
+	to := toY*width + toX
+	estimate := func(x, y int) float64 {
+		dx, dy := float64(toX-x), float64(toY-y)
+		return math.Sqrt(dx*dx+dy*dy) * minLandTypeCost
+	}
+
+	costs := map[int]float64{fromY*width + fromX: 0}
+	q := &pathQueue{{idx: fromY*width + fromX, estimate: estimate(fromX, fromY)}}
+	for q.Len() > 0 {
+		n := heap.Pop(q).(pathNode)
+		if n.idx == to {
+			return n.cost, nil
+		}
+		if n.cost > costs[n.idx] {
+			continue
+		}
+
+		x, y := n.idx%width, n.idx/width
+		for _, o := range xyOffsets {
+			nx, ny := x+o.x, y+o.y
+			if !t.size.Contains(nx, ny) {
+				continue
+			}
+			nidx := ny*width + nx
+
+			landCost := landTypeCosts[t.GetLandType(nx, ny)]
+			if nidx == to && math.IsInf(landCost, 1) {
+				landCost = 1
+			}
+			if math.IsInf(landCost, 1) {
+				continue
+			}
+
+			stepLength := 1.0
+			if o.x != 0 && o.y != 0 {
+				stepLength = math.Sqrt2
+			}
+			cost := n.cost + stepLength*landCost
+			if c, ok := costs[nidx]; ok && c <= cost {
+				continue
+			}
+			costs[nidx] = cost
+			heap.Push(q, pathNode{idx: nidx, cost: cost, estimate: cost + estimate(nx, ny)})
+		}
+	}
+
+	return 0, ErrNoPath
+}
+
+// Calculates the arrival time from now of a travel along the cheapest path
+// through the terrain. Crossing an entry of grass takes speed.
+func CalculateArrivalTime(terrain *Terrain, fromX, fromY, toX, toY int32, speed time.Duration) (int64, error) {
+	cost, err := terrain.FindPathCost(int(fromX), int(fromY), int(toX), int(toY))
+	if err != nil {
+		return 0, err
+	}
+	travelTime := cost * speed.Seconds()
+	return time.Now().UnixNano() + int64(travelTime*1e9), nil
+}
+
+type terrainKey struct {
+	seed uint64
+	size WorldSize
+}
+
+var terrains sync.Map
+
+// Returns the terrain of the world
+func (s *WorldService) GetTerrain(ctx context.Context) (*Terrain, error) {
+	seed, err := GetWorldSeed(ctx, s.r)
+	if err != nil {
+		return nil, err
+	}
+	size, err := s.GetSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := terrainKey{seed: DeriveSeed(seed, "terrain"), size: size}
+
+	if t, ok := terrains.Load(key); ok {
+		return t.(*Terrain), nil
+	}
+	t, _ := terrains.LoadOrStore(key, NewTerrain(key.seed, size))
+	return t.(*Terrain), nil
+}
+
+// Calculates the arrival time from now of a travel between the entries
+func (s *WorldService) CalculateArrivalTime(ctx context.Context, fromX, fromY, toX, toY int32, speed time.Duration) (int64, error) {
+	terrain, err := s.GetTerrain(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return CalculateArrivalTime(terrain, fromX, fromY, toX, toY, speed)
+}
+
+// Writes the land type of every entry to the zones, so that clients can
+// show the terrain
+func (s *WorldService) StoreTerrain(ctx context.Context) error {
+	terrain, err := s.GetTerrain(ctx)
+	if err != nil {
+		return err
+	}
+
+	for zx := 0; zx < terrain.size.ZonesX(); zx++ {
+		for zy := 0; zy < terrain.size.ZonesY(); zy++ {
+			zidx := getZoneIdxFromZoneXY(zx, zy)
+			zone, err := s.GetZoneIdx(ctx, zidx)
+			if err != nil {
+				return err
+			}
+			if zone == nil {
+				continue
+			}
+
+			_, err = s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for eidx := range zone.Entries {
+					x, y := getEntryXY(zidx, eidx)
+					landType := terrain.GetLandType(x, y)
+					err := RedisJsonSet(pipe, ctx, getZoneKey(zidx),
+						fmt.Sprintf(".entries[%d].landType", eidx),
+						fmt.Sprintf("\"%s\"", landType.String())).Err()
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to store terrain: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var testWorldSize = WorldSize{Width: WORLD_SIZE, Height: WORLD_SIZE}
+
+// Returns a terrain of only grass
+func newGrassTerrain() *Terrain {
+	return &Terrain{
+		size:      testWorldSize,
+		landTypes: make([]WorldEntry_LandType, WORLD_SIZE*WORLD_SIZE),
+	}
+}
+
+func TestNewTerrain(t *testing.T) {
+	a := NewTerrain(42, testWorldSize)
+	if diff := cmp.Diff(a.landTypes, NewTerrain(42, testWorldSize).landTypes); diff != "" {
+		t.Errorf("NewTerrain() is not deterministic (-want +got):\n%s", diff)
+	}
+	if cmp.Equal(a.landTypes, NewTerrain(43, testWorldSize).landTypes) {
+		t.Errorf("NewTerrain() gave the same terrain for different seeds")
+	}
+
+	// Growing the world keeps the land types of the existing entries
+	grown := NewTerrain(42, WorldSize{Width: WORLD_SIZE + WORLD_ZONE_SIZE, Height: WORLD_SIZE + WORLD_ZONE_SIZE})
+	for y := 0; y < WORLD_SIZE; y++ {
+		for x := 0; x < WORLD_SIZE; x++ {
+			if a.GetLandType(x, y) != grown.GetLandType(x, y) {
+				t.Fatalf("NewTerrain() changed the land type at %d, %d when grown", x, y)
+			}
+		}
+	}
+}
+
+func TestFindPathCost(t *testing.T) {
+	// A wall of mountains at x = 5 with a single gap at y = 9
+	wall := newGrassTerrain()
+	for y := 0; y < WORLD_SIZE; y++ {
+		if y != 9 {
+			wall.landTypes[y*WORLD_SIZE+5] = WorldEntry_MOUNTAIN
+		}
+	}
+
+	// A town enclosed by mountains at 20, 20
+	enclosed := newGrassTerrain()
+	for _, o := range xyOffsets {
+		enclosed.landTypes[(20+o.y)*WORLD_SIZE+20+o.x] = WorldEntry_MOUNTAIN
+	}
+
+	// A road along y = 0
+	road := newGrassTerrain()
+	for x := 0; x < WORLD_SIZE; x++ {
+		road.landTypes[x] = WorldEntry_ROAD
+	}
+
+	tests := map[string]struct {
+		terrain *Terrain
+		from    xy
+		to      xy
+		want    float64
+		wantErr error
+	}{
+		"same entry":         {terrain: newGrassTerrain(), from: xy{3, 3}, to: xy{3, 3}, want: 0},
+		"straight on grass":  {terrain: newGrassTerrain(), from: xy{0, 0}, to: xy{5, 0}, want: 5},
+		"diagonal on grass":  {terrain: newGrassTerrain(), from: xy{0, 0}, to: xy{3, 3}, want: 3 * math.Sqrt2},
+		"around a wall":      {terrain: wall, from: xy{3, 9}, to: xy{7, 9}, want: 4},
+		"detour through gap": {terrain: wall, from: xy{4, 0}, to: xy{6, 0}, want: 16 + 2*math.Sqrt2},
+		"along a road":       {terrain: road, from: xy{0, 0}, to: xy{10, 0}, want: 5},
+		"enclosed":           {terrain: enclosed, from: xy{0, 0}, to: xy{20, 20}, wantErr: ErrNoPath},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.terrain.FindPathCost(test.from.x, test.from.y, test.to.x, test.to.y)
+			if err != test.wantErr {
+				t.Fatalf("FindPathCost() error = %v, want %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("FindPathCost() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindPathCostMountainTown(t *testing.T) {
+	// Towns on mountains can still be reached
+	terrain := newGrassTerrain()
+	terrain.landTypes[2*WORLD_SIZE+2] = WorldEntry_MOUNTAIN
+
+	got, err := terrain.FindPathCost(0, 2, 2, 2)
+	if err != nil {
+		t.Fatalf("FindPathCost() error = %v", err)
+	}
+	if diff := cmp.Diff(2.0, got); diff != "" {
+		t.Errorf("FindPathCost() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCanPlaceTown(t *testing.T) {
+	// A wall of mountains at x = 5 that cuts off the smaller west side
+	wall := newGrassTerrain()
+	for y := 0; y < WORLD_SIZE; y++ {
+		wall.landTypes[y*WORLD_SIZE+5] = WorldEntry_MOUNTAIN
+	}
+
+	// A single entry of grass enclosed by mountains at 20, 20
+	enclosed := newGrassTerrain()
+	for _, o := range xyOffsets {
+		enclosed.landTypes[(20+o.y)*WORLD_SIZE+20+o.x] = WorldEntry_MOUNTAIN
+	}
+
+	tests := map[string]struct {
+		terrain *Terrain
+		at      xy
+		want    bool
+	}{
+		"grass":             {terrain: newGrassTerrain(), at: xy{3, 3}, want: true},
+		"outside the world": {terrain: newGrassTerrain(), at: xy{-1, 3}, want: false},
+		"mountain":          {terrain: wall, at: xy{5, 3}, want: false},
+		"smaller region":    {terrain: wall, at: xy{3, 3}, want: false},
+		"larger region":     {terrain: wall, at: xy{7, 3}, want: true},
+		"enclosed":          {terrain: enclosed, at: xy{20, 20}, want: false},
+		"next to enclosure": {terrain: enclosed, at: xy{22, 20}, want: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.terrain.CanPlaceTown(test.at.x, test.at.y)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CanPlaceTown() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCanPlaceTownGenerated(t *testing.T) {
+	// Generated terrains have pockets of passable entries that are cut off
+	// by mountains. Towns must not be placed in them, while the other
+	// entries must be reachable from each other.
+	size := WorldSize{Width: WORLD_SIZE, Height: WORLD_SIZE}
+	excluded := 0
+	for seed := uint64(1); seed <= 20; seed++ {
+		terrain := NewTerrain(seed, size)
+
+		towns := []xy{}
+		for y := 0; y < size.Height; y++ {
+			for x := 0; x < size.Width; x++ {
+				if terrain.CanPlaceTown(x, y) {
+					towns = append(towns, xy{x, y})
+				}
+			}
+		}
+		if len(towns) == 0 {
+			t.Fatalf("seed %d: no entry can have a town", seed)
+		}
+		anchor := towns[0]
+
+		for y := 0; y < size.Height; y++ {
+			for x := 0; x < size.Width; x++ {
+				if !IsPassable(terrain.GetLandType(x, y)) || terrain.CanPlaceTown(x, y) {
+					continue
+				}
+				excluded++
+				if _, err := terrain.FindPathCost(x, y, anchor.x, anchor.y); err != ErrNoPath {
+					t.Errorf("seed %d: %d, %d can reach %v but can not have a town", seed, x, y, anchor)
+				}
+			}
+		}
+
+		for i := 1; i < len(towns); i += len(towns) / 8 {
+			if _, err := terrain.FindPathCost(anchor.x, anchor.y, towns[i].x, towns[i].y); err != nil {
+				t.Errorf("seed %d: FindPathCost(%v, %v) error = %v", seed, anchor, towns[i], err)
+			}
+		}
+	}
+	if excluded == 0 {
+		t.Errorf("no generated terrain has a pocket")
+	}
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"sort"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+)
+
+// The number of mice that can be trained at the same time in a town without
+// a school, so that new towns can get started
+const DefaultTrainingCapacity = 5
+
+// Returns how many mice can be trained at the same time in the town and the
+// training speed multiplier. The capacity of all schools are added together,
+// while the speed is given by the best school. Towns without a working school
+// have the default capacity.
+func CountTrainingCapacity(gs *GameState) (capacity int32, speed float64) {
+	speed = 1.0
+	now := time.Now().UnixNano()
+	for _, lot := range gs.Lots {
+		info := FullGameData.Buildings[int32(lot.Building)]
+		if info == nil || int(lot.Level) >= len(info.LevelInfos) || IsLotDisabled(lot, now) {
+			continue
+		}
+		if t := info.LevelInfos[lot.Level].Trainer; t != nil {
+			capacity = capacity + t.Capacity
+			if t.Speed > speed {
+				speed = t.Speed
+			}
+		}
+	}
+	if capacity <= 0 {
+		capacity = DefaultTrainingCapacity
+	}
+
+	return capacity, speed
+}
+
+// Returns how long it takes to train one batch of the education in
+// nanoseconds, given the training speed multiplier of the town.
+func GetTrainingDuration(edu Education, speed float64) int64 {
+	eduInfo := FullGameData.Educations[int32(edu)]
+	if speed <= 0 {
+		speed = 1.0
+	}
+	return int64(float64(eduInfo.TrainTime) / speed * float64(time.Second))
+}
+
+// Returns the time (unix nano) when a batch of the specified amount can start
+// training. Batches are started in the order they were queued, so a batch can
+// never start before a batch that is ahead of it in the queue. Once all
+// batches ahead of it have started, it will start as soon as there is
+// enough capacity left.
+func GetTrainingStartTime(queue []*Training, capacity int32, amount int32, now int64) int64 {
+	start := now
+	for _, t := range queue {
+		start = Max(start, t.StartAt)
+	}
+
+	// Capacity can only be freed when a training is completed, so those
+	// are the only points in time that needs to be considered.
+	candidates := []int64{start}
+	for _, t := range queue {
+		if t.CompleteAt > start {
+			candidates = append(candidates, t.CompleteAt)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i] < candidates[j]
+	})
+
+	for _, c := range candidates {
+		var used int32 = 0
+		for _, t := range queue {
+			if t.CompleteAt > c {
+				used = used + t.Amount
+			}
+		}
+		if used+amount <= capacity {
+			return c
+		}
+	}
+
+	// The batch is larger than the capacity (this can happen if a school
+	// was razed). Let it train alone once everything else is done.
+	return candidates[len(candidates)-1]
+}
+
+// Splits the amount into batches that fit the training capacity and
+// schedules them after the trainings already in the queue. Returns the
+// new batches in the order they should be appended to the queue.
+func ScheduleTrainings(queue []*Training, capacity int32, speed float64, edu Education, amount int32, now int64) []*Training {
+	duration := GetTrainingDuration(edu, speed)
+	scheduled := append([]*Training{}, queue...)
+	batches := []*Training{}
+
+	for amount > 0 && capacity > 0 {
+		batchAmount := MinInt32(amount, capacity)
+		startAt := GetTrainingStartTime(scheduled, capacity, batchAmount, now)
+		batch := &Training{
+			StartAt:    startAt,
+			CompleteAt: startAt + duration,
+			Education:  edu,
+			Amount:     batchAmount,
+		}
+		scheduled = append(scheduled, batch)
+		batches = append(batches, batch)
+		amount = amount - batchAmount
+	}
+
+	return batches
+}
+
+// Reschedules the batches that have not yet started against the current
+// training capacity, e.g. after a school has been upgraded. Batches that
+// have already started are left as they are. Returns true if any batch
+// was rescheduled.
+func RescheduleTrainings(queue []*Training, capacity int32, now int64) ([]*Training, bool) {
+	if capacity <= 0 {
+		return queue, false
+	}
+
+	res := []*Training{}
+	pending := []*Training{}
+	for _, t := range queue {
+		if t.StartAt > now {
+			pending = append(pending, t)
+		} else {
+			res = append(res, t)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].StartAt < pending[j].StartAt
+	})
+
+	changed := false
+	for _, t := range pending {
+		startAt := GetTrainingStartTime(res, capacity, t.Amount, now)
+		if startAt != t.StartAt {
+			changed = true
+			t = &Training{
+				StartAt:    startAt,
+				CompleteAt: startAt + (t.CompleteAt - t.StartAt),
+				Education:  t.Education,
+				Amount:     t.Amount,
+			}
+		}
+		res = append(res, t)
+	}
+
+	return res, changed
+}
@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestGetTrainingStartTime(t *testing.T) {
+	type input struct {
+		queue    []*Training
+		capacity int32
+		amount   int32
+		now      int64
+	}
+
+	tests := map[string]struct {
+		input input
+		want  int64
+	}{
+		"empty queue": {
+			input: input{capacity: 10, amount: 5, now: 10},
+			want:  10,
+		},
+		"enough capacity": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 50, Amount: 5},
+				},
+				capacity: 10, amount: 5, now: 10,
+			},
+			want: 10,
+		},
+		"wait for capacity": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 50, Amount: 5},
+					{StartAt: 0, CompleteAt: 30, Amount: 3},
+				},
+				capacity: 10, amount: 5, now: 10,
+			},
+			want: 30,
+		},
+		"never before queued batches": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 50, Amount: 10},
+					{StartAt: 50, CompleteAt: 90, Amount: 2},
+				},
+				capacity: 10, amount: 1, now: 10,
+			},
+			want: 50,
+		},
+		"larger than capacity": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 50, Amount: 1},
+				},
+				capacity: 10, amount: 20, now: 10,
+			},
+			want: 50,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetTrainingStartTime(test.input.queue, test.input.capacity, test.input.amount, test.input.now)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetTrainingStartTime(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCountTrainingCapacity(t *testing.T) {
+	type want struct {
+		capacity int32
+		speed    float64
+	}
+
+	tests := map[string]struct {
+		gs   *GameState
+		want want
+	}{
+		"no school": {
+			gs:   &GameState{},
+			want: want{capacity: DefaultTrainingCapacity, speed: 1.0},
+		},
+		"schools": {
+			gs: &GameState{
+				Lots: map[string]*GameState_Lot{
+					"1": {Building: Building_SCHOOL, Level: 0},
+					"2": {Building: Building_SCHOOL, Level: 1},
+				},
+			},
+			want: want{capacity: 35, speed: 1.25},
+		},
+		"sabotaged school": {
+			gs: &GameState{
+				Lots: map[string]*GameState_Lot{
+					"1": {Building: Building_SCHOOL, Level: 0, DisabledUntil: math.MaxInt64},
+				},
+			},
+			want: want{capacity: DefaultTrainingCapacity, speed: 1.0},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			capacity, speed := CountTrainingCapacity(test.gs)
+			got := want{capacity: capacity, speed: speed}
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("CountTrainingCapacity(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScheduleTrainings(t *testing.T) {
+	d := GetTrainingDuration(Education_CHEF, 1.0)
+
+	type input struct {
+		queue    []*Training
+		capacity int32
+		speed    float64
+		amount   int32
+		now      int64
+	}
+
+	tests := map[string]struct {
+		input input
+		want  []*Training
+	}{
+		"single batch": {
+			input: input{capacity: 10, speed: 1.0, amount: 5, now: 10},
+			want: []*Training{
+				{StartAt: 10, CompleteAt: 10 + d, Education: Education_CHEF, Amount: 5},
+			},
+		},
+		"split into batches": {
+			input: input{capacity: 10, speed: 1.0, amount: 25, now: 10},
+			want: []*Training{
+				{StartAt: 10, CompleteAt: 10 + d, Education: Education_CHEF, Amount: 10},
+				{StartAt: 10 + d, CompleteAt: 10 + 2*d, Education: Education_CHEF, Amount: 10},
+				{StartAt: 10 + 2*d, CompleteAt: 10 + 3*d, Education: Education_CHEF, Amount: 5},
+			},
+		},
+		"after queued trainings": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 50, Education: Education_GUARD, Amount: 8},
+				},
+				capacity: 10, speed: 1.0, amount: 5, now: 10,
+			},
+			want: []*Training{
+				{StartAt: 50, CompleteAt: 50 + d, Education: Education_CHEF, Amount: 5},
+			},
+		},
+		"faster school": {
+			input: input{capacity: 10, speed: 2.0, amount: 5, now: 10},
+			want: []*Training{
+				{StartAt: 10, CompleteAt: 10 + d/2, Education: Education_CHEF, Amount: 5},
+			},
+		},
+		"no capacity": {
+			input: input{capacity: 0, speed: 1.0, amount: 5, now: 10},
+			want:  []*Training{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ScheduleTrainings(
+				test.input.queue, test.input.capacity, test.input.speed,
+				Education_CHEF, test.input.amount, test.input.now)
+			if diff := cmp.Diff(test.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("ScheduleTrainings(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRescheduleTrainings(t *testing.T) {
+	type input struct {
+		queue    []*Training
+		capacity int32
+		now      int64
+	}
+	type want struct {
+		queue   []*Training
+		changed bool
+	}
+
+	tests := map[string]struct {
+		input input
+		want  want
+	}{
+		"no capacity": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 200, CompleteAt: 400, Amount: 5},
+				},
+				capacity: 0, now: 100,
+			},
+			want: want{
+				queue: []*Training{
+					{StartAt: 200, CompleteAt: 400, Amount: 5},
+				},
+			},
+		},
+		"started trainings are kept": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 10},
+				},
+				capacity: 5, now: 100,
+			},
+			want: want{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 10},
+				},
+			},
+		},
+		"start earlier": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 5},
+					{StartAt: 200, CompleteAt: 400, Amount: 5},
+				},
+				capacity: 10, now: 100,
+			},
+			want: want{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 5},
+					{StartAt: 100, CompleteAt: 300, Amount: 5},
+				},
+				changed: true,
+			},
+		},
+		"start later": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 10},
+					{StartAt: 150, CompleteAt: 350, Amount: 5},
+				},
+				capacity: 10, now: 100,
+			},
+			want: want{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 10},
+					{StartAt: 200, CompleteAt: 400, Amount: 5},
+				},
+				changed: true,
+			},
+		},
+		"unchanged": {
+			input: input{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 5},
+					{StartAt: 200, CompleteAt: 400, Amount: 10},
+				},
+				capacity: 10, now: 100,
+			},
+			want: want{
+				queue: []*Training{
+					{StartAt: 0, CompleteAt: 200, Amount: 5},
+					{StartAt: 200, CompleteAt: 400, Amount: 10},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			queue, changed := RescheduleTrainings(test.input.queue, test.input.capacity, test.input.now)
+			got := want{queue: queue, changed: changed}
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(want{}), protocmp.Transform()); diff != "" {
+				t.Errorf("RescheduleTrainings(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+)
+
+// Both the sender and the recipient of a transfer must have played for at
+// least this long. This makes it harder to create new accounts just to
+// send their coins to a main account.
+const TransferMinAccountAge = 72 * time.Hour
+
+// How many coins a user can send and receive per day
+const TransferDailySendLimit = 20_000
+const TransferDailyReceiveLimit = 40_000
+
+// Returns how many couriers are needed to carry the coins
+func CountCouriers(coins int64) int32 {
+	return int32(math.Ceil(float64(coins) / float64(CourierCapacity)))
+}
+
+// Returns the indexes of the world zones that a travel between the two
+// positions passes through, in the order they are passed.
+func GetRouteZones(fromX, fromY, toX, toY int32) []int {
+	dx := float64(toX - fromX)
+	dy := float64(toY - fromY)
+	steps := int(math.Ceil(math.Max(math.Abs(dx), math.Abs(dy))))
+
+	zones := []int{}
+	seen := map[int]bool{}
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		x := int(math.Round(float64(fromX) + dx*t))
+		y := int(math.Round(float64(fromY) + dy*t))
+		zidx := getZoneIdx(x, y)
+		if !seen[zidx] {
+			seen[zidx] = true
+			zones = append(zones, zidx)
+		}
+	}
+
+	return zones
+}
+
+func checkAccountAge(userId string, now time.Time) error {
+	id, err := xid.FromString(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+	if now.Sub(id.Time()) < TransferMinAccountAge {
+		return errors.New("account is too new to transfer coins")
+	}
+	return nil
+}
+
+// Adds the amount to a daily transfer counter and returns an error if the
+// counter goes above the limit. The amount is not counted in that case.
+func useTransferLimit(ctx context.Context, r redis.Cmdable, key string, amount int64, limit int64) error {
+	// The counter is created together with its expiry, so that it can never
+	// be left without one
+	var incr *redis.IntCmd
+	_, err := r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key, 0, 24*time.Hour)
+		incr = pipe.IncrBy(ctx, key, amount)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check transfer limit: %w", err)
+	}
+	if incr.Val() > limit {
+		if err = r.DecrBy(ctx, key, amount).Err(); err != nil {
+			return fmt.Errorf("failed to restore transfer limit: %w", err)
+		}
+		return errors.New("daily transfer limit exceeded")
+	}
+	return nil
+}
+
+// Validates that coins can be transferred between the users, and counts the
+// coins against the daily limits of both users. Returns a function that
+// gives the coins back to the limits, for when the transfer can not be made.
+func UseTransferLimits(ctx context.Context, r redis.Cmdable, senderId string, recipientId string, coins int64) (func() error, error) {
+	if senderId == recipientId {
+		return nil, errors.New("can't transfer coins to self")
+	}
+
+	now := time.Now()
+	if err := checkAccountAge(senderId, now); err != nil {
+		return nil, err
+	}
+	if err := checkAccountAge(recipientId, now); err != nil {
+		return nil, err
+	}
+
+	date := now.UTC().Format("2006-01-02")
+	sentKey := fmt.Sprintf("user:%s:transfersSent:%s", senderId, date)
+	receivedKey := fmt.Sprintf("user:%s:transfersReceived:%s", recipientId, date)
+
+	if err := useTransferLimit(ctx, r, sentKey, coins, TransferDailySendLimit); err != nil {
+		return nil, err
+	}
+	if err := useTransferLimit(ctx, r, receivedKey, coins, TransferDailyReceiveLimit); err != nil {
+		if err2 := r.DecrBy(ctx, sentKey, coins).Err(); err2 != nil {
+			return nil, fmt.Errorf("failed to restore transfer limit: %w", err2)
+		}
+		return nil, err
+	}
+
+	release := func() error {
+		_, err := r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.DecrBy(ctx, sentKey, coins)
+			pipe.DecrBy(ctx, receivedKey, coins)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore transfer limits: %w", err)
+		}
+		return nil
+	}
+
+	return release, nil
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCountCouriers(t *testing.T) {
+	tests := map[string]struct {
+		coins int64
+		want  int32
+	}{
+		"one coin":       {coins: 1, want: 1},
+		"full capacity":  {coins: CourierCapacity, want: 1},
+		"above capacity": {coins: CourierCapacity + 1, want: 2},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CountCouriers(test.coins)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CountCouriers(%d) mismatch (-want +got):\n%s", test.coins, diff)
+			}
+		})
+	}
+}
+
+func TestGetRouteZones(t *testing.T) {
+	type input struct {
+		fromX, fromY, toX, toY int32
+	}
+
+	tests := map[string]struct {
+		input input
+		want  []int
+	}{
+		"same zone": {
+			input: input{1, 1, 8, 8},
+			want:  []int{0},
+		},
+		"horizontal": {
+			input: input{5, 5, 25, 5},
+			want:  []int{0, 1, 2},
+		},
+		"vertical backwards": {
+			input: input{5, 25, 5, 5},
+			want:  []int{22, 11, 0},
+		},
+		"diagonal": {
+			input: input{5, 5, 15, 15},
+			want:  []int{0, 12},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetRouteZones(test.input.fromX, test.input.fromY, test.input.toX, test.input.toY)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetRouteZones(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,16 +1,95 @@
 package internal
 
 import (
-	"math"
-	"time"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/protobuf/proto"
 )
 
-// Calculates the arrival time from now.
-// Returns the travel time in nanoseconds.
-func CalculateArrivalTime(fromX, fromY, toX, toY int32, speed time.Duration) int64 {
-	dx := toX - fromX
-	dy := toY - fromY
-	distance := math.Sqrt(float64(dx*dx) + float64(dy*dy))
-	travelTime := distance * speed.Seconds()
-	return time.Now().UnixNano() + int64(travelTime*1e9)
+// Turns an outbound travel around at the time now. The travel returns from
+// its current position, so the way back takes as long as the time it has
+// been travelling.
+func RecallTravel(travel *Travel, now int64) (*Travel, error) {
+	if travel.Returning {
+		return nil, errors.New("travel is already returning")
+	}
+	if travel.DepartureAt == 0 {
+		return nil, errors.New("travel can not be recalled")
+	}
+	if travel.ArrivalAt <= now {
+		return nil, errors.New("travel has already arrived")
+	}
+
+	elapsed := Max(now-travel.DepartureAt, 0)
+
+	recalled := proto.Clone(travel).(*Travel)
+	recalled.Returning = true
+	recalled.DepartureAt = now
+	recalled.ArrivalAt = now + elapsed
+
+	return recalled, nil
+}
+
+// Sorts the travel queue by arrival time, keeping the order of travels that
+// arrive at the same time
+func SortTravelQueue(travelQueue []*Travel) {
+	sort.SliceStable(travelQueue, func(i, j int) bool {
+		return travelQueue[i].ArrivalAt < travelQueue[j].ArrivalAt
+	})
+}
+
+// Returns the index where the travel should be inserted to keep the travel
+// queue sorted by arrival time. Travels that arrive at the same time are
+// kept in the order they were added.
+func GetTravelInsertIndex(travelQueue []*Travel, travel *Travel) int {
+	return sort.Search(len(travelQueue), func(i int) bool {
+		return travelQueue[i].ArrivalAt > travel.ArrivalAt
+	})
+}
+
+// Inserts the travel into the travel queue of the game state, so that the
+// queue stays sorted by arrival time. The travel queue must be the current
+// queue of the game state.
+func InsertTravel(ctx context.Context, pipe redis.Pipeliner, gsKey string, travelQueue []*Travel, travel *Travel) error {
+	b, err := protojson.Marshal(travel)
+	if err != nil {
+		return fmt.Errorf("failed to marshal travel: %w", err)
+	}
+
+	idx := GetTravelInsertIndex(travelQueue, travel)
+	if idx == len(travelQueue) {
+		err = RedisJsonArrAppend(pipe, ctx, gsKey, ".travelQueue", b).Err()
+	} else {
+		err = RedisJsonArrInsert(pipe, ctx, gsKey, ".travelQueue", idx, b).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert travel: %w", err)
+	}
+
+	return nil
+}
+
+// Splits the travel queue into the travels that have arrived at the time
+// now and the ones that are still on their way. The whole queue is checked,
+// so a travel that arrives later never holds back one behind it.
+func SplitCompletedTravels(travelQueue []*Travel, now int64) (completed []*Travel, pending []*Travel) {
+	pending = []*Travel{}
+	for _, t := range travelQueue {
+		if t.ArrivalAt <= now {
+			completed = append(completed, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+
+	// Complete travels in the order they arrived
+	SortTravelQueue(completed)
+
+	return completed, pending
 }
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRecallTravel(t *testing.T) {
+	tests := map[string]struct {
+		travel  *Travel
+		now     int64
+		want    *Travel
+		wantErr bool
+	}{
+		"halfway": {
+			travel: &Travel{Id: "a", DepartureAt: 100, ArrivalAt: 300, Thieves: 5},
+			now:    200,
+			want:   &Travel{Id: "a", DepartureAt: 200, ArrivalAt: 300, Thieves: 5, Returning: true},
+		},
+		"just departed": {
+			travel: &Travel{Id: "a", DepartureAt: 100, ArrivalAt: 300, Scouts: 1},
+			now:    110,
+			want:   &Travel{Id: "a", DepartureAt: 110, ArrivalAt: 120, Scouts: 1, Returning: true},
+		},
+		"already returning": {
+			travel:  &Travel{Id: "a", DepartureAt: 100, ArrivalAt: 300, Returning: true},
+			now:     200,
+			wantErr: true,
+		},
+		"already arrived": {
+			travel:  &Travel{Id: "a", DepartureAt: 100, ArrivalAt: 300},
+			now:     300,
+			wantErr: true,
+		},
+		"unknown departure": {
+			travel:  &Travel{ArrivalAt: 300},
+			now:     200,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := RecallTravel(test.travel, test.now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RecallTravel() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("RecallTravel() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortTravelQueue(t *testing.T) {
+	queue := []*Travel{
+		{Id: "a", ArrivalAt: 300},
+		{Id: "b", ArrivalAt: 100},
+		{Id: "c", ArrivalAt: 300},
+		{Id: "d", ArrivalAt: 200},
+	}
+
+	SortTravelQueue(queue)
+
+	got := []string{}
+	for _, t := range queue {
+		got = append(got, t.Id)
+	}
+	if diff := cmp.Diff([]string{"b", "d", "a", "c"}, got); diff != "" {
+		t.Errorf("SortTravelQueue() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetTravelInsertIndex(t *testing.T) {
+	queue := []*Travel{
+		{ArrivalAt: 100},
+		{ArrivalAt: 200},
+		{ArrivalAt: 200},
+		{ArrivalAt: 300},
+	}
+
+	tests := map[string]struct {
+		arrivalAt int64
+		want      int
+	}{
+		"first":           {arrivalAt: 50, want: 0},
+		"between":         {arrivalAt: 150, want: 1},
+		"after same time": {arrivalAt: 200, want: 3},
+		"last":            {arrivalAt: 400, want: 4},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := GetTravelInsertIndex(queue, &Travel{ArrivalAt: test.arrivalAt})
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetTravelInsertIndex() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSplitCompletedTravels(t *testing.T) {
+	type result struct {
+		Completed []string
+		Pending   []string
+	}
+
+	tests := map[string]struct {
+		queue []*Travel
+		now   int64
+		want  result
+	}{
+		"nothing arrived": {
+			queue: []*Travel{{Id: "a", ArrivalAt: 200}},
+			now:   100,
+			want:  result{Pending: []string{"a"}},
+		},
+		"sorted": {
+			queue: []*Travel{
+				{Id: "a", ArrivalAt: 100},
+				{Id: "b", ArrivalAt: 200},
+				{Id: "c", ArrivalAt: 300},
+			},
+			now:  200,
+			want: result{Completed: []string{"a", "b"}, Pending: []string{"c"}},
+		},
+		"long travel first": {
+			queue: []*Travel{
+				{Id: "a", ArrivalAt: 500},
+				{Id: "b", ArrivalAt: 200},
+				{Id: "c", ArrivalAt: 100},
+			},
+			now:  300,
+			want: result{Completed: []string{"c", "b"}, Pending: []string{"a"}},
+		},
+	}
+
+	ids := func(travels []*Travel) (res []string) {
+		for _, t := range travels {
+			res = append(res, t.Id)
+		}
+		return res
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			completed, pending := SplitCompletedTravels(test.queue, test.now)
+			got := result{Completed: ids(completed), Pending: ids(pending)}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("SplitCompletedTravels() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/xid"
+)
+
+const TribeMaxMembers = 20
+const TribeMaxDescriptionLength = 500
+
+var tribeNameRegexp = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} '\-]{1,28}[\p{L}\p{N}]$`)
+var tribeTagRegexp = regexp.MustCompile(`^[A-Z0-9]{2,4}$`)
+
+func getTribeKey(tribeId string) string {
+	return fmt.Sprintf("tribe:%s", tribeId)
+}
+
+func getUserTribeInvitationsKey(userId string) string {
+	return fmt.Sprintf("user:%s:tribeInvitations", userId)
+}
+
+// Returns the member with the user id, or nil if the user is not a member
+func GetTribeMember(tribe *Tribe, userId string) *Tribe_Member {
+	for _, m := range tribe.Members {
+		if m.UserId == userId {
+			return m
+		}
+	}
+	return nil
+}
+
+func removeTribeMember(tribe *Tribe, userId string) {
+	members := []*Tribe_Member{}
+	for _, m := range tribe.Members {
+		if m.UserId != userId {
+			members = append(members, m)
+		}
+	}
+	tribe.Members = members
+}
+
+// Returns the member that should become leader when the leader leaves the
+// tribe. Officers are preferred over members, and then whoever joined first.
+func GetNextTribeLeader(tribe *Tribe) *Tribe_Member {
+	candidates := []*Tribe_Member{}
+	for _, m := range tribe.Members {
+		if m.Role != Tribe_LEADER {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Role != candidates[j].Role {
+			return candidates[i].Role > candidates[j].Role
+		}
+		return candidates[i].JoinedAt < candidates[j].JoinedAt
+	})
+
+	return candidates[0]
+}
+
+// Returns true if a member with the role can kick a member with the other
+// role. Leaders can kick anyone, while officers can only kick members.
+func CanKickTribeMember(role Tribe_Role, other Tribe_Role) bool {
+	switch role {
+	case Tribe_LEADER:
+		return other != Tribe_LEADER
+	case Tribe_OFFICER:
+		return other == Tribe_MEMBER
+	default:
+		return false
+	}
+}
+
+type TribeService struct {
+	r RedisClient
+}
+
+func NewTribeService(r RedisClient) *TribeService {
+	return &TribeService{r: r}
+}
+
+func (s *TribeService) Get(ctx context.Context, tribeId string) (*Tribe, error) {
+	str, err := s.r.Get(ctx, getTribeKey(tribeId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tribe: %w", err)
+	}
+
+	tribe := &Tribe{}
+	if err = protojson.Unmarshal([]byte(str), tribe); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tribe: %w", err)
+	}
+
+	return tribe, nil
+}
+
+// Returns the id of the tribe that the user is a member of, or an empty
+// string if the user is not in a tribe.
+func (s *TribeService) GetUserTribeId(ctx context.Context, userId string) (string, error) {
+	tribeId, err := s.r.HGet(ctx, fmt.Sprintf("user:%s", userId), "tribeId").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return tribeId, err
+}
+
+// Returns true if both users are members of the same tribe
+func (s *TribeService) AreAllies(ctx context.Context, userId string, otherId string) (bool, error) {
+	tribeId, err := s.GetUserTribeId(ctx, userId)
+	if err != nil || tribeId == "" {
+		return false, err
+	}
+	otherTribeId, err := s.GetUserTribeId(ctx, otherId)
+	if err != nil {
+		return false, err
+	}
+	return tribeId == otherTribeId, nil
+}
+
+// Returns the tribe that the user is a member of, or nil if the user is not
+// in a tribe.
+func (s *TribeService) GetUserTribe(ctx context.Context, userId string) (*Tribe, error) {
+	tribeId, err := s.GetUserTribeId(ctx, userId)
+	if err != nil || tribeId == "" {
+		return nil, err
+	}
+	return s.Get(ctx, tribeId)
+}
+
+func (s *TribeService) GetInvitations(ctx context.Context, userId string) (*TribeInvitations, error) {
+	res, err := s.r.HGetAll(ctx, getUserTribeInvitationsKey(userId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tribe invitations: %w", err)
+	}
+
+	invitations := &TribeInvitations{}
+	for _, str := range res {
+		inv := &TribeInvitation{}
+		if err = protojson.Unmarshal([]byte(str), inv); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tribe invitation: %w", err)
+		}
+		invitations.Invitations = append(invitations.Invitations, inv)
+	}
+	sort.Slice(invitations.Invitations, func(i, j int) bool {
+		return invitations.Invitations[i].CreatedAt < invitations.Invitations[j].CreatedAt
+	})
+
+	return invitations, nil
+}
+
+func (s *TribeService) save(ctx context.Context, pipe redis.Pipeliner, tribe *Tribe) error {
+	b, err := protojson.Marshal(tribe)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tribe: %w", err)
+	}
+	pipe.Set(ctx, getTribeKey(tribe.Id), b, 0)
+	return nil
+}
+
+// Locks and reads the tribe that the user is a member of, and calls f with
+// the tribe and the member. The tribe is saved if f returns without error.
+func (s *TribeService) update(ctx context.Context, userId string, f func(pipe redis.Pipeliner, tribe *Tribe, member *Tribe_Member) error) error {
+	tribeId, err := s.GetUserTribeId(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if tribeId == "" {
+		return errors.New("not in a tribe")
+	}
+
+	mutex := s.r.NewMutex("lock:" + getTribeKey(tribeId))
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	defer mutex.Unlock()
+
+	tribe, err := s.Get(ctx, tribeId)
+	if err != nil {
+		return err
+	}
+	member := GetTribeMember(tribe, userId)
+	if member == nil {
+		return errors.New("not a member of the tribe")
+	}
+
+	_, err = s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if err := f(pipe, tribe, member); err != nil {
+			return err
+		}
+		if len(tribe.Members) == 0 {
+			return nil
+		}
+		return s.save(ctx, pipe, tribe)
+	})
+
+	return err
+}
+
+func (s *TribeService) Create(ctx context.Context, userId string, name string, tag string, description string) (*Tribe, error) {
+	name = strings.TrimSpace(name)
+	tag = strings.ToUpper(strings.TrimSpace(tag))
+
+	if !tribeNameRegexp.MatchString(name) {
+		return nil, errors.New("invalid tribe name")
+	}
+	if !tribeTagRegexp.MatchString(tag) {
+		return nil, errors.New("invalid tribe tag")
+	}
+	if len(description) > TribeMaxDescriptionLength {
+		return nil, errors.New("tribe description is too long")
+	}
+
+	username, err := s.r.HGet(ctx, fmt.Sprintf("user:%s", userId), "username").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get username: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+	tribe := &Tribe{
+		Id:          xid.New().String(),
+		Name:        name,
+		Tag:         tag,
+		Description: description,
+		CreatedAt:   now,
+		Members: []*Tribe_Member{
+			{
+				UserId:   userId,
+				Username: username,
+				Role:     Tribe_LEADER,
+				JoinedAt: now,
+			},
+		},
+	}
+
+	// Claim the user, name and tag one at a time and release what has been
+	// claimed if any of them is already taken.
+	userKey := fmt.Sprintf("user:%s", userId)
+	ok, err := s.r.HSetNX(ctx, userKey, "tribeId", tribe.Id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tribe: %w", err)
+	}
+	if !ok {
+		return nil, errors.New("already in a tribe")
+	}
+	ok, err = s.r.HSetNX(ctx, "tribe_names", strings.ToLower(name), tribe.Id).Result()
+	if err != nil || !ok {
+		s.r.HDel(ctx, userKey, "tribeId")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tribe: %w", err)
+		}
+		return nil, errors.New("tribe name is already taken")
+	}
+	ok, err = s.r.HSetNX(ctx, "tribe_tags", tag, tribe.Id).Result()
+	if err != nil || !ok {
+		s.r.HDel(ctx, userKey, "tribeId")
+		s.r.HDel(ctx, "tribe_names", strings.ToLower(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tribe: %w", err)
+		}
+		return nil, errors.New("tribe tag is already taken")
+	}
+
+	_, err = s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return s.save(ctx, pipe, tribe)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tribe: %w", err)
+	}
+
+	return tribe, nil
+}
+
+// Invites the user with the username to the tribe of the user. Only leaders
+// and officers can invite new members.
+func (s *TribeService) Invite(ctx context.Context, userId string, username string) error {
+	targetUserId, err := s.r.Get(ctx, fmt.Sprintf("username:%s", strings.ToLower(username))).Result()
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return s.update(ctx, userId, func(pipe redis.Pipeliner, tribe *Tribe, member *Tribe_Member) error {
+		if member.Role == Tribe_MEMBER {
+			return errors.New("only leaders and officers can invite")
+		}
+		if GetTribeMember(tribe, targetUserId) != nil {
+			return errors.New("user is already a member of the tribe")
+		}
+		if len(tribe.Members) >= TribeMaxMembers {
+			return errors.New("tribe is full")
+		}
+
+		b, err := protojson.Marshal(&TribeInvitation{
+			TribeId:   tribe.Id,
+			TribeName: tribe.Name,
+			TribeTag:  tribe.Tag,
+			InvitedBy: member.Username,
+			CreatedAt: time.Now().UnixNano(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal tribe invitation: %w", err)
+		}
+		pipe.HSet(ctx, getUserTribeInvitationsKey(targetUserId), tribe.Id, b)
+
+		return nil
+	})
+}
+
+// Lets the user join a tribe that the user has been invited to
+func (s *TribeService) Join(ctx context.Context, userId string, tribeId string) error {
+	invitationsKey := getUserTribeInvitationsKey(userId)
+	invited, err := s.r.HExists(ctx, invitationsKey, tribeId).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get tribe invitation: %w", err)
+	}
+	if !invited {
+		return errors.New("not invited to the tribe")
+	}
+
+	username, err := s.r.HGet(ctx, fmt.Sprintf("user:%s", userId), "username").Result()
+	if err != nil {
+		return fmt.Errorf("failed to get username: %w", err)
+	}
+
+	mutex := s.r.NewMutex("lock:" + getTribeKey(tribeId))
+	if err := mutex.Lock(); err != nil {
+		return fmt.Errorf("failed to obtain lock: %w", err)
+	}
+	defer mutex.Unlock()
+
+	tribe, err := s.Get(ctx, tribeId)
+	if errors.Is(err, redis.Nil) {
+		// The tribe has been disbanded since the invitation was sent
+		s.r.HDel(ctx, invitationsKey, tribeId)
+		return errors.New("tribe does not exist")
+	}
+	if err != nil {
+		return err
+	}
+	if len(tribe.Members) >= TribeMaxMembers {
+		return errors.New("tribe is full")
+	}
+
+	userKey := fmt.Sprintf("user:%s", userId)
+	ok, err := s.r.HSetNX(ctx, userKey, "tribeId", tribeId).Result()
+	if err != nil {
+		return fmt.Errorf("failed to join tribe: %w", err)
+	}
+	if !ok {
+		return errors.New("already in a tribe")
+	}
+
+	tribe.Members = append(tribe.Members, &Tribe_Member{
+		UserId:   userId,
+		Username: username,
+		Role:     Tribe_MEMBER,
+		JoinedAt: time.Now().UnixNano(),
+	})
+
+	_, err = s.r.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, invitationsKey, tribeId)
+		return s.save(ctx, pipe, tribe)
+	})
+	if err != nil {
+		s.r.HDel(ctx, userKey, "tribeId")
+		return fmt.Errorf("failed to join tribe: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TribeService) DeclineInvitation(ctx context.Context, userId string, tribeId string) error {
+	return s.r.HDel(ctx, getUserTribeInvitationsKey(userId), tribeId).Err()
+}
+
+// Lets the user leave the tribe. If the leader leaves, the leadership is
+// passed on to another member. The tribe is disbanded when the last member
+// leaves.
+func (s *TribeService) Leave(ctx context.Context, userId string) error {
+	var formerAllies []string
+	err := s.update(ctx, userId, func(pipe redis.Pipeliner, tribe *Tribe, member *Tribe_Member) error {
+		removeTribeMember(tribe, userId)
+		pipe.HDel(ctx, fmt.Sprintf("user:%s", userId), "tribeId")
+		formerAllies = getTribeMemberIds(tribe)
+
+		if len(tribe.Members) == 0 {
+			pipe.Del(ctx, getTribeKey(tribe.Id))
+			pipe.HDel(ctx, "tribe_names", strings.ToLower(tribe.Name))
+			pipe.HDel(ctx, "tribe_tags", tribe.Tag)
+			return nil
+		}
+
+		if member.Role == Tribe_LEADER {
+			GetNextTribeLeader(tribe).Role = Tribe_LEADER
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.recallReinforcements(ctx, userId, formerAllies)
+}
+
+func (s *TribeService) Kick(ctx context.Context, userId string, targetUserId string) error {
+	var formerAllies []string
+	err := s.update(ctx, userId, func(pipe redis.Pipeliner, tribe *Tribe, member *Tribe_Member) error {
+		target := GetTribeMember(tribe, targetUserId)
+		if target == nil {
+			return errors.New("user is not a member of the tribe")
+		}
+		if !CanKickTribeMember(member.Role, target.Role) {
+			return errors.New("not allowed to kick the member")
+		}
+
+		removeTribeMember(tribe, targetUserId)
+		pipe.HDel(ctx, fmt.Sprintf("user:%s", targetUserId), "tribeId")
+		formerAllies = getTribeMemberIds(tribe)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.recallReinforcements(ctx, targetUserId, formerAllies)
+}
+
+func getTribeMemberIds(tribe *Tribe) []string {
+	ids := make([]string, len(tribe.Members))
+	for i, m := range tribe.Members {
+		ids[i] = m.UserId
+	}
+	return ids
+}
+
+// Sends home the guards that the user and its former allies have stationed
+// in each others towns, since guards can only be stationed with allies
+func (s *TribeService) recallReinforcements(ctx context.Context, userId string, formerAllies []string) error {
+	world := NewWorldService(s.r)
+	for _, allyId := range formerAllies {
+		if _, err := RecallReinforcements(ctx, s.r, world, userId, allyId); err != nil {
+			return err
+		}
+		if _, err := RecallReinforcements(ctx, s.r, world, allyId, userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets the role of a member. Only the leader can change roles, and making
+// someone else leader makes the current leader an officer.
+func (s *TribeService) SetRole(ctx context.Context, userId string, targetUserId string, role Tribe_Role) error {
+	return s.update(ctx, userId, func(pipe redis.Pipeliner, tribe *Tribe, member *Tribe_Member) error {
+		if member.Role != Tribe_LEADER {
+			return errors.New("only the leader can change roles")
+		}
+		if targetUserId == userId {
+			return errors.New("can't change own role")
+		}
+		target := GetTribeMember(tribe, targetUserId)
+		if target == nil {
+			return errors.New("user is not a member of the tribe")
+		}
+
+		target.Role = role
+		if role == Tribe_LEADER {
+			member.Role = Tribe_OFFICER
+		}
+
+		return nil
+	})
+}
+
+func (s *TribeService) UpdateProfile(ctx context.Context, userId string, description string) error {
+	if len(description) > TribeMaxDescriptionLength {
+		return errors.New("tribe description is too long")
+	}
+
+	return s.update(ctx, userId, func(pipe redis.Pipeliner, tribe *Tribe, member *Tribe_Member) error {
+		if member.Role != Tribe_LEADER {
+			return errors.New("only the leader can update the tribe profile")
+		}
+		tribe.Description = description
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetNextTribeLeader(t *testing.T) {
+	tests := map[string]struct {
+		members []*Tribe_Member
+		want    string
+	}{
+		"no other members": {
+			members: []*Tribe_Member{
+				{UserId: "a", Role: Tribe_LEADER, JoinedAt: 1},
+			},
+			want: "",
+		},
+		"oldest member": {
+			members: []*Tribe_Member{
+				{UserId: "a", Role: Tribe_LEADER, JoinedAt: 1},
+				{UserId: "b", Role: Tribe_MEMBER, JoinedAt: 3},
+				{UserId: "c", Role: Tribe_MEMBER, JoinedAt: 2},
+			},
+			want: "c",
+		},
+		"officers first": {
+			members: []*Tribe_Member{
+				{UserId: "a", Role: Tribe_LEADER, JoinedAt: 1},
+				{UserId: "b", Role: Tribe_MEMBER, JoinedAt: 2},
+				{UserId: "c", Role: Tribe_OFFICER, JoinedAt: 4},
+				{UserId: "d", Role: Tribe_OFFICER, JoinedAt: 3},
+			},
+			want: "d",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if m := GetNextTribeLeader(&Tribe{Members: test.members}); m != nil {
+				got = m.UserId
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetNextTribeLeader(...) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCanKickTribeMember(t *testing.T) {
+	tests := map[string]struct {
+		role  Tribe_Role
+		other Tribe_Role
+		want  bool
+	}{
+		"leader kicks officer":  {role: Tribe_LEADER, other: Tribe_OFFICER, want: true},
+		"leader kicks member":   {role: Tribe_LEADER, other: Tribe_MEMBER, want: true},
+		"officer kicks member":  {role: Tribe_OFFICER, other: Tribe_MEMBER, want: true},
+		"officer kicks officer": {role: Tribe_OFFICER, other: Tribe_OFFICER, want: false},
+		"officer kicks leader":  {role: Tribe_OFFICER, other: Tribe_LEADER, want: false},
+		"member kicks member":   {role: Tribe_MEMBER, other: Tribe_MEMBER, want: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CanKickTribeMember(test.role, test.other)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CanKickTribeMember(%s, %s) mismatch (-want +got):\n%s", test.role, test.other, diff)
+			}
+		})
+	}
+}
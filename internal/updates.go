@@ -19,6 +19,14 @@ func GetNextUpdateTimestamp(gs *GameState) int64 {
 	for i := range gs.TravelQueue {
 		t = Min(t, gs.TravelQueue[i].ArrivalAt)
 	}
+	for _, lot := range gs.Lots {
+		if IsLotDisabled(lot, time.Now().UnixNano()) {
+			t = Min(t, lot.DisabledUntil)
+		}
+	}
+	for _, injury := range gs.Injuries {
+		t = Min(t, injury.RecoveredAt)
+	}
 
 	// Make the update time at least 100ms in the future to avoid
 	// update loops in case of failures.
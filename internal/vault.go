@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"time"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+)
+
+// Returns the number of coins that the vaults of the town protect from
+// thieves. Unlike builder's guilds, vaults stack.
+func CountProtectedCoins(gs *GameState) int32 {
+	var protected int32 = 0
+	now := time.Now().UnixNano()
+	for _, lot := range gs.Lots {
+		info := FullGameData.Buildings[int32(lot.Building)]
+		if info == nil || int(lot.Level) >= len(info.LevelInfos) || IsLotDisabled(lot, now) {
+			continue
+		}
+		if v := info.LevelInfos[lot.Level].Vault; v != nil {
+			protected = protected + v.ProtectedCoins
+		}
+	}
+
+	return protected
+}
+
+// Returns the number of coins in the town that are not protected by vaults
+func CountStealableCoins(gs *GameState) int32 {
+	if gs.Resources == nil {
+		return 0
+	}
+	return MaxInt32(gs.Resources.Coins-CountProtectedCoins(gs), 0)
+}
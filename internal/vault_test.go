@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"testing"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCountStealableCoins(t *testing.T) {
+	tests := map[string]struct {
+		gs   *GameState
+		want int32
+	}{
+		"no vault": {
+			gs: &GameState{
+				Resources: &GameState_Resources{Coins: 50_000},
+			},
+			want: 50_000,
+		},
+		"one vault": {
+			gs: &GameState{
+				Resources: &GameState_Resources{Coins: 50_000},
+				Lots: map[string]*GameState_Lot{
+					"1": {Building: Building_VAULT, Level: 1},
+				},
+			},
+			want: 10_000,
+		},
+		"vaults stack": {
+			gs: &GameState{
+				Resources: &GameState_Resources{Coins: 50_000},
+				Lots: map[string]*GameState_Lot{
+					"1": {Building: Building_VAULT, Level: 0},
+					"2": {Building: Building_VAULT, Level: 0},
+				},
+			},
+			want: 30_000,
+		},
+		"everything protected": {
+			gs: &GameState{
+				Resources: &GameState_Resources{Coins: 5_000},
+				Lots: map[string]*GameState_Lot{
+					"1": {Building: Building_VAULT, Level: 0},
+				},
+			},
+			want: 0,
+		},
+		"disabled vault": {
+			gs: &GameState{
+				Resources: &GameState_Resources{Coins: 50_000},
+				Lots: map[string]*GameState_Lot{
+					"1": {Building: Building_VAULT, Level: 0, DisabledUntil: 1 << 62},
+				},
+			},
+			want: 50_000,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := CountStealableCoins(test.gs)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("CountStealableCoins() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/fnatte/pizza-tribes/internal/models"
+	"github.com/fnatte/pizza-tribes/internal/protojson"
+	"github.com/go-redis/redis/v8"
+)
+
+// The max number of zones that can be fetched in one viewport
+const ViewportMaxZones = 36
+
+var ErrViewportTooLarge = errors.New("viewport is too large")
+
+// Returns the indexes of the zones that overlap the bounding box, which
+// includes both corners. The bounding box is clamped to the world.
+func getViewportZones(size WorldSize, x1, y1, x2, y2 int) ([]int, error) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	x1 = int(Max(int64(x1), 0))
+	y1 = int(Max(int64(y1), 0))
+	x2 = int(Min(int64(x2), int64(size.Width-1)))
+	y2 = int(Min(int64(y2), int64(size.Height-1)))
+	if x1 > x2 || y1 > y2 {
+		return []int{}, nil
+	}
+
+	minZx, maxZx := x1/WORLD_ZONE_SIZE, x2/WORLD_ZONE_SIZE
+	minZy, maxZy := y1/WORLD_ZONE_SIZE, y2/WORLD_ZONE_SIZE
+	if (maxZx-minZx+1)*(maxZy-minZy+1) > ViewportMaxZones {
+		return nil, ErrViewportTooLarge
+	}
+
+	zidxs := []int{}
+	for zy := minZy; zy <= maxZy; zy++ {
+		for zx := minZx; zx <= maxZx; zx++ {
+			zidxs = append(zidxs, getZoneIdxFromZoneXY(zx, zy))
+		}
+	}
+	return zidxs, nil
+}
+
+// Returns the zones that overlap the bounding box together with the
+// usernames of the towns in them
+func (s *WorldService) GetViewport(ctx context.Context, x1, y1, x2, y2 int) (*WorldViewport, error) {
+	size, err := s.GetSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zidxs, err := getViewportZones(size, x1, y1, x2, y2)
+	if err != nil {
+		return nil, err
+	}
+
+	viewport := &WorldViewport{
+		Zones:     []*WorldViewport_Zone{},
+		Usernames: map[string]string{},
+	}
+	if len(zidxs) == 0 {
+		return viewport, nil
+	}
+
+	keys := make([]string, len(zidxs))
+	for i, zidx := range zidxs {
+		keys[i] = getZoneKey(zidx)
+	}
+
+	// All zones are fetched in a single round trip
+	res, err := RedisJsonMGet(s.r, ctx, ".", keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+
+	userIds := []string{}
+	for i, v := range res {
+		str, ok := v.(string)
+		if !ok {
+			// The zone does not exist
+			continue
+		}
+		zone := &WorldZone{}
+		if err = protojson.Unmarshal([]byte(str), zone); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal zone: %w", err)
+		}
+
+		x, y := getEntryXY(zidxs[i], 0)
+		viewport.Zones = append(viewport.Zones, &WorldViewport_Zone{
+			Idx:  int32(zidxs[i]),
+			X:    int32(x),
+			Y:    int32(y),
+			Zone: zone,
+		})
+
+		for _, e := range zone.Entries {
+			if town := e.GetTown(); town != nil {
+				userIds = append(userIds, town.UserId)
+			}
+		}
+	}
+
+	if len(userIds) == 0 {
+		return viewport, nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(userIds))
+	_, err = s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, userId := range userIds {
+			cmds[i] = pipe.HGet(ctx, fmt.Sprintf("user:%s", userId), "username")
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get usernames: %w", err)
+	}
+	for i, cmd := range cmds {
+		if username, err := cmd.Result(); err == nil {
+			viewport.Usernames[userIds[i]] = username
+		}
+	}
+
+	return viewport, nil
+}
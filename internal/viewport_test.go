@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetViewportZones(t *testing.T) {
+	size := WorldSize{Width: WORLD_SIZE, Height: WORLD_SIZE}
+
+	tests := map[string]struct {
+		bbox    [4]int
+		want    []int
+		wantErr error
+	}{
+		"single zone":      {bbox: [4]int{2, 3, 8, 9}, want: []int{0}},
+		"corners included": {bbox: [4]int{9, 9, 10, 10}, want: []int{0, 1, 11, 12}},
+		"swapped corners":  {bbox: [4]int{15, 5, 5, 5}, want: []int{0, 1}},
+		"clamped to world": {bbox: [4]int{-20, 105, 5, 200}, want: []int{110}},
+		"outside of world": {bbox: [4]int{200, 200, 300, 300}, want: []int{}},
+		"max zones": {bbox: [4]int{0, 0, 59, 59}, want: []int{
+			0, 1, 2, 3, 4, 5,
+			11, 12, 13, 14, 15, 16,
+			22, 23, 24, 25, 26, 27,
+			33, 34, 35, 36, 37, 38,
+			44, 45, 46, 47, 48, 49,
+			55, 56, 57, 58, 59, 60,
+		}},
+		"too large": {bbox: [4]int{0, 0, 60, 59}, wantErr: ErrViewportTooLarge},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := getViewportZones(size, test.bbox[0], test.bbox[1], test.bbox[2], test.bbox[3])
+			if err != test.wantErr {
+				t.Fatalf("getViewportZones() error = %v, want %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("getViewportZones() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"math/rand"
 	"strconv"
 
 	. "github.com/fnatte/pizza-tribes/internal/models"
 	"github.com/fnatte/pizza-tribes/internal/protojson"
 	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/rand"
 )
 
+// The initial size of the world. The current size is stored in the world
+// meta, see GetSize.
 const WORLD_SIZE = 110
 const WORLD_ZONE_SIZE = 10
 const WORLD_NUM_ZONES = (WORLD_SIZE / WORLD_ZONE_SIZE) * (WORLD_SIZE / WORLD_ZONE_SIZE)
@@ -34,16 +37,57 @@ func getEntryIdx(x, y int) int {
 	return y*WORLD_ZONE_SIZE + x
 }
 
+// The zones of the world before it could be resized were indexed row by
+// row with this many zones per row
+const legacyZonesPerRow = WORLD_SIZE / WORLD_ZONE_SIZE
+
+// Returns the index of the zone at zx, zy, counted in zones. The zones of
+// the initial world keep their row by row index, and the zones outside of
+// it are numbered shell by shell around the origin, so that the index of a
+// zone does not change when the world grows.
+func getZoneIdxFromZoneXY(zx, zy int) int {
+	if zx < legacyZonesPerRow && zy < legacyZonesPerRow {
+		return zy*legacyZonesPerRow + zx
+	}
+	if zx < zy {
+		return zy*zy + zx
+	}
+	return zx*zx + zx + zy
+}
+
+// Returns the zone coordinates, counted in zones, of the zone index
+func getZoneXY(zidx int) (zx, zy int) {
+	if zidx < legacyZonesPerRow*legacyZonesPerRow {
+		return zidx % legacyZonesPerRow, zidx / legacyZonesPerRow
+	}
+	shell := int(math.Sqrt(float64(zidx)))
+	for shell*shell > zidx {
+		shell--
+	}
+	for (shell+1)*(shell+1) <= zidx {
+		shell++
+	}
+	rest := zidx - shell*shell
+	if rest < shell {
+		return rest, shell
+	}
+	return shell, rest - shell
+}
+
+// Returns the coordinates of the entry in the zone
+func getEntryXY(zidx, eidx int) (x, y int) {
+	zx, zy := getZoneXY(zidx)
+	return zx*WORLD_ZONE_SIZE + eidx%WORLD_ZONE_SIZE, zy*WORLD_ZONE_SIZE + eidx/WORLD_ZONE_SIZE
+}
+
 func getIdx(x, y int) (zidx, eidx int) {
-	zy := y / WORLD_ZONE_SIZE
-	zx := x / WORLD_ZONE_SIZE
-	zidx = zy*(WORLD_SIZE/WORLD_ZONE_SIZE) + zx
+	zidx = getZoneIdx(x, y)
 	eidx = (y%WORLD_ZONE_SIZE)*WORLD_ZONE_SIZE + (x % WORLD_ZONE_SIZE)
 	return
 }
 
 func getZoneIdx(x, y int) int {
-	return (y/WORLD_ZONE_SIZE)*(WORLD_SIZE/WORLD_ZONE_SIZE) + (x / WORLD_ZONE_SIZE)
+	return getZoneIdxFromZoneXY(x/WORLD_ZONE_SIZE, y/WORLD_ZONE_SIZE)
 }
 
 func getZoneKey(idx int) string {
@@ -87,6 +131,20 @@ func (s *WorldService) setEntryXY(ctx context.Context, x, y int, e *WorldEntry)
 func (s *WorldService) AcquireTown(ctx context.Context, userId string) (x, y int, err error) {
 	var zidx, eidx int
 
+	// The placement is derived from the world seed so that it can be
+	// reproduced
+	worldSeed, err := GetWorldSeed(ctx, s.r)
+	if err != nil {
+		return 0, 0, err
+	}
+	seed := DeriveSeed(worldSeed, "town", userId)
+	rnd := rand.New(rand.NewSource(seed))
+
+	terrain, err := s.GetTerrain(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
 	// Loop until we find a spot
 	for {
 		zidxes, err := s.r.ZRange(ctx, "world:open_zones", 0, 0).Result()
@@ -101,29 +159,35 @@ func (s *WorldService) AcquireTown(ctx context.Context, userId string) (x, y int
 			return 0, 0, err
 		}
 
-		// Get random entry
-		ex := rand.Intn(WORLD_ZONE_SIZE)
-		ey := rand.Intn(WORLD_ZONE_SIZE)
-		eidx = getEntryIdx(ex, ey)
-
-		// Convert from index to x,y
-		zy := zidx / (WORLD_SIZE / WORLD_ZONE_SIZE)
-		zx := zidx % (WORLD_SIZE / WORLD_ZONE_SIZE)
-		x = zx*(WORLD_ZONE_SIZE) + ex
-		y = zy*(WORLD_ZONE_SIZE) + ey
-
 		zone, err := s.GetZoneIdx(ctx, zidx)
 		if err != nil {
 			return 0, 0, err
 		}
 
-		if zone != nil && eidx < len(zone.Entries) {
-			e := zone.Entries[eidx]
-			if e.GetTown() != nil {
+		// Towns can only be placed on free entries that can be travelled
+		// to and from every other town
+		free := []int{}
+		for eidx := 0; eidx < WORLD_ZONE_SIZE*WORLD_ZONE_SIZE; eidx++ {
+			if zone != nil && eidx < len(zone.Entries) && zone.Entries[eidx].GetTown() != nil {
+				continue
+			}
+			ex, ey := getEntryXY(zidx, eidx)
+			if !terrain.CanPlaceTown(ex, ey) {
 				continue
 			}
+			free = append(free, eidx)
+		}
+		if len(free) == 0 {
+			if err = s.closeZone(ctx, zidx); err != nil {
+				return 0, 0, err
+			}
+			continue
 		}
 
+		// Get random entry
+		eidx = free[rnd.Intn(len(free))]
+		x, y = getEntryXY(zidx, eidx)
+
 		break
 	}
 
@@ -150,6 +214,9 @@ func (s *WorldService) AcquireTown(ctx context.Context, userId string) (x, y int
 	if err != nil {
 		return 0, 0, err
 	}
+	if err = IndexTown(ctx, s.r, userId, x, y).Err(); err != nil {
+		return 0, 0, err
+	}
 
 	// Close zone if it is fully populated
 	zone, err := s.GetZoneIdx(ctx, zidx)
@@ -160,6 +227,13 @@ func (s *WorldService) AcquireTown(ctx context.Context, userId string) (x, y int
 		s.closeZone(ctx, zidx)
 	}
 
+	log.Info().
+		Str("userId", userId).
+		Uint64("seed", seed).
+		Int("x", x).
+		Int("y", y).
+		Msg("Acquired town")
+
 	return
 }
 
@@ -195,15 +269,21 @@ func (s *WorldService) GetEntryXY(ctx context.Context, x, y int) (*WorldEntry, e
 		return nil, err
 	}
 
-	if eidx >= len(zone.Entries) {
+	if zone == nil || eidx >= len(zone.Entries) {
 		return nil, errors.New("entry not found")
 	}
 
 	return zone.Entries[eidx], nil
 }
 
+type TownLocation struct {
+	UserId string
+	X      int
+	Y      int
+}
+
 func (s *WorldService) closeZone(ctx context.Context, zidx int) error {
-	return s.r.ZRem(ctx, fmt.Sprintf("world:zone:%d", zidx)).Err()
+	return s.r.ZRem(ctx, "world:open_zones", zidx).Err()
 }
 
 func (s *WorldService) tryOpenZone(ctx context.Context, zidx int, score float64) error {
@@ -229,12 +309,18 @@ func (s *WorldService) tryOpenZone(ctx context.Context, zidx int, score float64)
 }
 
 func (s *WorldService) Initilize(ctx context.Context) error {
-	w := WORLD_SIZE / WORLD_ZONE_SIZE
-	h := WORLD_SIZE / WORLD_ZONE_SIZE
+	if _, err := GetWorldSeed(ctx, s.r); err != nil {
+		return err
+	}
+
+	size, err := s.ensureSize(ctx)
+	if err != nil {
+		return err
+	}
 
-	for x := 0; x < w; x++ {
-		for y := 0; y < h; y++ {
-			idx := y*WORLD_ZONE_SIZE + x
+	for x := 0; x < size.ZonesX(); x++ {
+		for y := 0; y < size.ZonesY(); y++ {
+			idx := getZoneIdxFromZoneXY(x, y)
 			b, err := protojson.Marshal(&WorldZone{
 				Entries: make([]*WorldEntry, WORLD_ZONE_SIZE*WORLD_ZONE_SIZE),
 			})
@@ -253,11 +339,11 @@ func (s *WorldService) Initilize(ctx context.Context) error {
 	// Populate open zones. A zone will only be opened if there are no towns in it.
 	// Loop through each zone and set its score to its distance from the center
 	// This makes the zones closest to the center to be filled first.
-	cx := WORLD_SIZE / WORLD_ZONE_SIZE / 2
-	cy := WORLD_SIZE / WORLD_ZONE_SIZE / 2
-	for x := 0; x < WORLD_SIZE/WORLD_ZONE_SIZE; x++ {
-		for y := 0; y < WORLD_SIZE/WORLD_ZONE_SIZE; y++ {
-			zidx, _ := getIdx(x*WORLD_ZONE_SIZE, y*WORLD_ZONE_SIZE)
+	cx := size.ZonesX() / 2
+	cy := size.ZonesY() / 2
+	for x := 0; x < size.ZonesX(); x++ {
+		for y := 0; y < size.ZonesY(); y++ {
+			zidx := getZoneIdxFromZoneXY(x, y)
 			dx := cx - x
 			dy := cy - y
 			d := math.Sqrt(float64(dx*dx + dy*dy))
@@ -42,6 +42,22 @@ func TestGetZoneIdx(t *testing.T) {
 			input: input{ x: 99, y: 99 },
 			want: 108,
 		},
+		"last initial zone": {
+			input: input{ x: 109, y: 109 },
+			want: 120,
+		},
+		"grown zone x": {
+			input: input{ x: 115, y: 5 },
+			want: 132,
+		},
+		"grown zone y": {
+			input: input{ x: 5, y: 115 },
+			want: 121,
+		},
+		"grown zone both x and y": {
+			input: input{ x: 115, y: 115 },
+			want: 143,
+		},
 	}
 
 	for name, test := range tests {
@@ -99,3 +115,22 @@ func TestGetIdx(t *testing.T) {
 	}
 
 }
+
+func TestGetZoneXY(t *testing.T) {
+	// Every zone of a grown world has its own index, which maps back to the zone
+	seen := map[int]bool{}
+	for zy := 0; zy < 30; zy++ {
+		for zx := 0; zx < 30; zx++ {
+			zidx := getZoneIdxFromZoneXY(zx, zy)
+			if seen[zidx] {
+				t.Fatalf("getZoneIdxFromZoneXY(%d, %d) = %d is not unique", zx, zy, zidx)
+			}
+			seen[zidx] = true
+
+			gotX, gotY := getZoneXY(zidx)
+			if diff := cmp.Diff(input{ x: zx, y: zy }, input{ x: gotX, y: gotY }, cmp.AllowUnexported(input{})); diff != "" {
+				t.Errorf("getZoneXY(%d) mismatch (-want +got):\n%s", zidx, diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+const worldMetaKey = "world:meta"
+
+// The world is grown when fewer zones than this are open for new towns
+const WorldMinOpenZones = 8
+
+// The world is never grown beyond this many entries in each direction
+const WorldMaxSize = 550
+
+// The number of entries in each direction of the world. The world is
+// anchored at 0, 0 and grows along the positive axes, so that the
+// coordinates of towns never change.
+type WorldSize struct {
+	Width  int
+	Height int
+}
+
+func (s WorldSize) Contains(x, y int) bool {
+	return x >= 0 && y >= 0 && x < s.Width && y < s.Height
+}
+
+func (s WorldSize) ZonesX() int {
+	return s.Width / WORLD_ZONE_SIZE
+}
+
+func (s WorldSize) ZonesY() int {
+	return s.Height / WORLD_ZONE_SIZE
+}
+
+// Returns the size of the world. Worlds created before the size was stored
+// have the initial size.
+func (s *WorldService) GetSize(ctx context.Context) (WorldSize, error) {
+	meta, err := s.r.HGetAll(ctx, worldMetaKey).Result()
+	if err != nil {
+		return WorldSize{}, fmt.Errorf("failed to get world meta: %w", err)
+	}
+
+	size := WorldSize{Width: WORLD_SIZE, Height: WORLD_SIZE}
+	if v, ok := meta["width"]; ok {
+		if size.Width, err = strconv.Atoi(v); err != nil {
+			return WorldSize{}, fmt.Errorf("invalid world width: %w", err)
+		}
+	}
+	if v, ok := meta["height"]; ok {
+		if size.Height, err = strconv.Atoi(v); err != nil {
+			return WorldSize{}, fmt.Errorf("invalid world height: %w", err)
+		}
+	}
+
+	return size, nil
+}
+
+// Stores the initial size of the world unless it already has one
+func (s *WorldService) ensureSize(ctx context.Context) (WorldSize, error) {
+	_, err := s.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, worldMetaKey, "width", WORLD_SIZE)
+		pipe.HSetNX(ctx, worldMetaKey, "height", WORLD_SIZE)
+		return nil
+	})
+	if err != nil {
+		return WorldSize{}, fmt.Errorf("failed to set world size: %w", err)
+	}
+	return s.GetSize(ctx)
+}
+
+// Grows the world by a row and a column of zones until enough zones are
+// open, or until the world has reached its max size. Returns whether the
+// world was grown.
+func (s *WorldService) GrowWhenLow(ctx context.Context) (bool, error) {
+	grown := false
+
+	for {
+		open, err := s.r.ZCard(ctx, "world:open_zones").Result()
+		if err != nil {
+			return grown, fmt.Errorf("failed to count open zones: %w", err)
+		}
+		if open >= WorldMinOpenZones {
+			return grown, nil
+		}
+
+		size, err := s.GetSize(ctx)
+		if err != nil {
+			return grown, err
+		}
+		if size.Width+WORLD_ZONE_SIZE > WorldMaxSize || size.Height+WORLD_ZONE_SIZE > WorldMaxSize {
+			log.Warn().Int64("openZones", open).Msg("World has reached its max size")
+			return grown, nil
+		}
+
+		size.Width += WORLD_ZONE_SIZE
+		size.Height += WORLD_ZONE_SIZE
+		err = s.r.HSet(ctx, worldMetaKey, "width", size.Width, "height", size.Height).Err()
+		if err != nil {
+			return grown, fmt.Errorf("failed to set world size: %w", err)
+		}
+
+		// Create and open the new zones
+		if err = s.Initilize(ctx); err != nil {
+			return grown, err
+		}
+		grown = true
+
+		log.Info().
+			Int("width", size.Width).
+			Int("height", size.Height).
+			Msg("Grew world")
+	}
+}